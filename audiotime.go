@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// 音频文件的时间由内置的读取器直接从文件中读取，不依赖 exiftool：
+// ID3v2（MP3）、MP4 mvhd（M4A）、Broadcast WAV bext（WAV）和 Vorbis 注释（FLAC、Ogg Vorbis/Opus）。
+// 这些标签在音乐文件中常常只是发行年份或日期，因此只采用带有时刻的值。
+
+// audioTime 是从音频文件中读到的一个候选时间。
+type audioTime struct {
+	value string // exiftool 格式的时间（2006:01:02 15:04:05[-07:00]）；UTC 时刻以 Z 结尾
+	tag   string // 来源标签，用于输出，如 "ID3 TDRC"
+	utc   bool   // value 是 UTC 时刻（MP4 mvhd），其中的 Z 不代表录音地的时区
+}
+
+// maxAudioHeader 限制了读取 ID3v2 标签、bext 块和 Vorbis 注释时一次读入的字节数，防止损坏的文件耗尽内存。
+const maxAudioHeader = 16 << 20
+
+// mp4Epoch 是 MP4/QuickTime 时间的起点。
+var mp4Epoch = time.Date(1904, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// audioTimestampPattern 匹配 ID3v2.4 和 Vorbis DATE 中的 ISO 8601 时间（至少精确到分钟），可以带时区偏移。
+var audioTimestampPattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})[T ](\d{2}):(\d{2})(?::(\d{2}))?(Z|[+-]\d{2}:?\d{2})?$`)
+
+// readAudioTimes 按优先级返回音频文件中的所有候选时间。文件类型由文件头判断，而不是扩展名。
+func readAudioTimes(path string) ([]audioTime, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+
+	head := make([]byte, 12)
+	if _, err := io.ReadFull(f, head); err != nil { return nil, nil }
+	if _, err := f.Seek(0, io.SeekStart); err != nil { return nil, err }
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return readID3Times(f)
+	case string(head[0:4]) == "RIFF" && string(head[8:12]) == "WAVE":
+		return readBextTimes(f)
+	case string(head[0:4]) == "fLaC":
+		return readFLACTimes(f)
+	case string(head[0:4]) == "OggS":
+		return readOggTimes(f)
+	case string(head[4:8]) == "ftyp":
+		return readMvhdTimes(f)
+	}
+	return nil, nil
+}
+
+// --- ID3v2 ---
+
+// readID3Times 读取 ID3v2.3/2.4 标签中的 TDRC（录音时间）和 TDOR（最初发行时间），
+// 以及 ID3v2.3 中由 TYER、TDAT、TIME 拼成的录音时间。
+func readID3Times(r io.Reader) ([]audioTime, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil { return nil, err }
+	version, flags := header[3], header[5]
+	if version != 3 && version != 4 { return nil, nil }
+	size := syncsafe(header[6:10])
+	if size > maxAudioHeader { return nil, fmt.Errorf("ID3 tag too large (%d bytes)", size) }
+	tag := make([]byte, size)
+	if _, err := io.ReadFull(r, tag); err != nil { return nil, err }
+	// 整个标签使用了反同步（unsynchronisation）时，先还原 0xFF 0x00 序列
+	if flags&0x80 != 0 { tag = bytes.ReplaceAll(tag, []byte{0xFF, 0x00}, []byte{0xFF}) }
+	// 跳过扩展头
+	if flags&0x40 != 0 && len(tag) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(tag[0:4]))
+		if version == 4 { extSize = syncsafe(tag[0:4]) } else { extSize += 4 }
+		if extSize > len(tag) { return nil, nil }
+		tag = tag[extSize:]
+	}
+
+	frames := make(map[string]string)
+	for len(tag) >= 10 && tag[0] != 0 {
+		id := string(tag[0:4])
+		frameSize := int(binary.BigEndian.Uint32(tag[4:8]))
+		if version == 4 { frameSize = syncsafe(tag[4:8]) }
+		if frameSize < 0 || 10+frameSize > len(tag) { break }
+		if id[0] == 'T' { frames[id] = decodeID3Text(tag[10 : 10+frameSize]) }
+		tag = tag[10+frameSize:]
+	}
+
+	var times []audioTime
+	for _, id := range []string{"TDRC", "TDOR"} {
+		if value, ok := isoAudioTime(frames[id]); ok { times = append(times, audioTime{value: value, tag: "ID3 " + id}) }
+	}
+	// ID3v2.3：TYER = YYYY，TDAT = DDMM，TIME = HHMM
+	if year, date, clock := frames["TYER"], frames["TDAT"], frames["TIME"]; len(year) == 4 && len(date) == 4 && len(clock) == 4 {
+		value := fmt.Sprintf("%s:%s:%s %s:%s:00", year, date[2:4], date[0:2], clock[0:2], clock[2:4])
+		times = append(times, audioTime{value: value, tag: "ID3 TYER+TDAT+TIME"})
+	}
+	return times, nil
+}
+
+// syncsafe 解码 ID3v2 中每字节只使用低 7 位的整数。
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// decodeID3Text 解码 ID3v2 文本帧：第一个字节是编码（0 = ISO-8859-1，1 = 带 BOM 的 UTF-16，2 = UTF-16BE，3 = UTF-8）。
+func decodeID3Text(data []byte) string {
+	if len(data) == 0 { return "" }
+	encoding, data := data[0], data[1:]
+	var s string
+	switch encoding {
+	case 1, 2:
+		bigEndian := encoding == 2
+		if len(data) >= 2 && data[0] == 0xFE && data[1] == 0xFF { bigEndian, data = true, data[2:] }
+		if len(data) >= 2 && data[0] == 0xFF && data[1] == 0xFE { bigEndian, data = false, data[2:] }
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if bigEndian { units[i] = binary.BigEndian.Uint16(data[2*i:]) } else { units[i] = binary.LittleEndian.Uint16(data[2*i:]) }
+		}
+		s = string(utf16.Decode(units))
+	case 0:
+		runes := make([]rune, len(data))
+		for i, b := range data { runes[i] = rune(b) }
+		s = string(runes)
+	default:
+		s = string(data)
+	}
+	// 多个值以 NUL 分隔，只取第一个
+	if i := strings.IndexRune(s, 0); i >= 0 { s = s[:i] }
+	return strings.TrimSpace(s)
+}
+
+// isoAudioTime 把 ISO 8601 时间转换为 exiftool 格式。只有日期或年份的值不被采用。
+func isoAudioTime(s string) (string, bool) {
+	m := audioTimestampPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil { return "", false }
+	seconds := m[6]
+	if seconds == "" { seconds = "00" }
+	offset := m[7]
+	if len(offset) == 5 { offset = offset[:3] + ":" + offset[3:] }
+	return fmt.Sprintf("%s:%s:%s %s:%s:%s%s", m[1], m[2], m[3], m[4], m[5], seconds, offset), true
+}
+
+// --- Broadcast WAV ---
+
+// bext 块中 OriginationDate（yyyy-mm-dd）和 OriginationTime（hh:mm:ss）的位置。
+// 规范允许日期和时间的各部分之间使用任意分隔符，读取时统一替换为 "-" 和 ":"。
+const (
+	bextOriginationOffset = 256 + 32 + 32 // Description、Originator、OriginatorReference 之后
+	bextOriginationLength = 10 + 8
+)
+
+// readBextTimes 读取 Broadcast WAV 文件 bext 块中的录音日期和时间（录音机的当地时间）。
+func readBextTimes(r io.ReadSeeker) ([]audioTime, error) {
+	if _, err := r.Seek(12, io.SeekStart); err != nil { return nil, err }
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, chunk); err != nil { return nil, nil }
+		id, size := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		if id != "bext" {
+			if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil { return nil, err }
+			continue
+		}
+		if size < bextOriginationOffset+bextOriginationLength { return nil, nil }
+		data := make([]byte, bextOriginationOffset+bextOriginationLength)
+		if _, err := io.ReadFull(r, data); err != nil { return nil, err }
+		field := data[bextOriginationOffset:]
+		date, clock := append([]byte{}, field[0:10]...), append([]byte{}, field[10:18]...)
+		for _, i := range []int{4, 7} { date[i] = '-' }
+		for _, i := range []int{2, 5} { clock[i] = ':' }
+		value, ok := isoAudioTime(string(date) + " " + string(clock))
+		if !ok { return nil, nil }
+		return []audioTime{{value: value, tag: "BWF OriginationDate+OriginationTime"}}, nil
+	}
+}
+
+// --- MP4 ---
+
+// readMvhdTimes 读取 MP4 文件 moov/mvhd 中的创建时间（自 1904 年起的 UTC 秒数）。
+func readMvhdTimes(r io.ReadSeeker) ([]audioTime, error) {
+	moovSize, err := findMP4Box(r, "moov", -1)
+	if err != nil || moovSize < 0 { return nil, err }
+	mvhdSize, err := findMP4Box(r, "mvhd", moovSize)
+	if err != nil || mvhdSize < 0 { return nil, err }
+	data := make([]byte, 12)
+	if mvhdSize < int64(len(data)) { return nil, nil }
+	if _, err := io.ReadFull(r, data); err != nil { return nil, err }
+	var seconds uint64
+	if data[0] == 1 {
+		seconds = binary.BigEndian.Uint64(data[4:12])
+	} else {
+		seconds = uint64(binary.BigEndian.Uint32(data[4:8]))
+	}
+	if seconds == 0 { return nil, nil }
+	t := mp4Epoch.Add(time.Duration(seconds) * time.Second)
+	return []audioTime{{value: t.Format("2006:01:02 15:04:05Z07:00"), tag: "MP4 mvhd", utc: true}}, nil
+}
+
+// findMP4Box 从当前位置开始，在 limit 字节（-1 表示直到文件末尾）之内查找指定类型的 box，
+// 找到时停在 box 的内容处并返回内容的长度，找不到时返回 -1。
+func findMP4Box(r io.ReadSeeker, boxType string, limit int64) (int64, error) {
+	header := make([]byte, 8)
+	for limit < 0 || limit >= 8 {
+		if _, err := io.ReadFull(r, header); err != nil { return -1, nil }
+		size, headerSize := int64(binary.BigEndian.Uint32(header[0:4])), int64(8)
+		switch size {
+		case 0: // 一直延续到文件末尾，之后不会再有其他 box
+			if string(header[4:8]) != boxType { return -1, nil }
+			current, err := r.Seek(0, io.SeekCurrent)
+			if err != nil { return -1, err }
+			end, err := r.Seek(0, io.SeekEnd)
+			if err != nil { return -1, err }
+			if _, err := r.Seek(current, io.SeekStart); err != nil { return -1, err }
+			return end - current, nil
+		case 1: // 64 位长度
+			large := make([]byte, 8)
+			if _, err := io.ReadFull(r, large); err != nil { return -1, nil }
+			size, headerSize = int64(binary.BigEndian.Uint64(large)), 16
+		}
+		if size < headerSize { return -1, nil }
+		if string(header[4:8]) == boxType { return size - headerSize, nil }
+		if _, err := r.Seek(size-headerSize, io.SeekCurrent); err != nil { return -1, err }
+		if limit >= 0 { limit -= size }
+	}
+	return -1, nil
+}
+
+// --- Vorbis 注释 ---
+
+// readFLACTimes 读取 FLAC 文件 VORBIS_COMMENT 元数据块中的 DATE。
+func readFLACTimes(r io.Reader) ([]audioTime, error) {
+	if _, err := io.ReadFull(r, make([]byte, 4)); err != nil { return nil, err }
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil { return nil, nil }
+		last, blockType := header[0]&0x80 != 0, header[0]&0x7F
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		block := make([]byte, size)
+		if _, err := io.ReadFull(r, block); err != nil { return nil, nil }
+		if blockType == 4 { return vorbisCommentTimes(block, "FLAC"), nil }
+		if last { return nil, nil }
+	}
+}
+
+// readOggTimes 读取 Ogg Vorbis 或 Opus 文件第二个数据包（注释头）中的 DATE。
+func readOggTimes(r io.Reader) ([]audioTime, error) {
+	var packets [][]byte
+	var current []byte
+	header := make([]byte, 27)
+	for len(packets) < 2 && len(current) <= maxAudioHeader {
+		if _, err := io.ReadFull(r, header); err != nil || string(header[0:4]) != "OggS" { return nil, nil }
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil { return nil, nil }
+		for _, length := range segments {
+			data := make([]byte, length)
+			if _, err := io.ReadFull(r, data); err != nil { return nil, nil }
+			current = append(current, data...)
+			// 长度小于 255 的段结束一个数据包
+			if length < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+	}
+	if len(packets) < 2 { return nil, nil }
+	comment := packets[1]
+	switch {
+	case bytes.HasPrefix(comment, []byte("\x03vorbis")):
+		return vorbisCommentTimes(comment[7:], "Vorbis"), nil
+	case bytes.HasPrefix(comment, []byte("OpusTags")):
+		return vorbisCommentTimes(comment[8:], "Opus"), nil
+	}
+	return nil, nil
+}
+
+// vorbisCommentTimes 解析 Vorbis 注释结构（小端长度的厂商字符串和 KEY=value 列表），返回其中的 DATE。
+func vorbisCommentTimes(data []byte, format string) []audioTime {
+	if len(data) < 4 { return nil }
+	vendor := int(binary.LittleEndian.Uint32(data))
+	if 4+vendor+4 > len(data) { return nil }
+	data = data[4+vendor:]
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+	var times []audioTime
+	for i := 0; i < count && len(data) >= 4; i++ {
+		length := int(binary.LittleEndian.Uint32(data))
+		if 4+length > len(data) { break }
+		key, value, ok := strings.Cut(string(data[4:4+length]), "=")
+		data = data[4+length:]
+		if !ok || !strings.EqualFold(key, "DATE") { continue }
+		if t, ok := isoAudioTime(value); ok { times = append(times, audioTime{value: t, tag: format + " DATE"}) }
+	}
+	return times
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAudioFixture 把 data 写入临时文件并用 readAudioTimes 读取，同时检查按文件头选择读取器的逻辑。
+func readAudioFixture(t *testing.T, data []byte) []audioTime {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "audio")
+	if err := os.WriteFile(path, data, 0o644); err != nil { t.Fatal(err) }
+	times, err := readAudioTimes(path)
+	if err != nil { t.Fatalf("readAudioTimes: %v", err) }
+	return times
+}
+
+// syncsafeBytes 编码 ID3v2 的 syncsafe 整数。
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7F), byte(n >> 14 & 0x7F), byte(n >> 7 & 0x7F), byte(n & 0x7F)}
+}
+
+// id3Frame 构造一个 ID3v2 帧。ID3v2.4 的帧长度是 syncsafe 整数，ID3v2.3 是普通的大端整数。
+func id3Frame(version byte, id string, content []byte) []byte {
+	b := []byte(id)
+	if version == 4 { b = append(b, syncsafeBytes(len(content))...) } else { b = binary.BigEndian.AppendUint32(b, uint32(len(content))) }
+	b = append(b, 0, 0)
+	return append(b, content...)
+}
+
+// id3Text 构造使用 UTF-8 或 ISO-8859-1 编码的文本帧内容。
+func id3Text(encoding byte, s string) []byte {
+	return append([]byte{encoding}, s...)
+}
+
+// id3Tag 构造一个 ID3v2 标签，其后是一个 MPEG 帧头。
+func id3Tag(version byte, frames ...[]byte) []byte {
+	body := bytes.Join(frames, nil)
+	body = append(body, make([]byte, 64)...) // 填充
+	b := append([]byte{'I', 'D', '3', version, 0, 0}, syncsafeBytes(len(body))...)
+	b = append(b, body...)
+	return append(b, 0xFF, 0xFB, 0x90, 0x64)
+}
+
+func TestReadID3Times(t *testing.T) {
+	// 超过 127 字节的帧和标签：长度按 syncsafe 解码与按大端解码的结果不同
+	comment := id3Frame(4, "COMM", append([]byte{3, 'e', 'n', 'g', 0}, strings.Repeat("x", 200)...))
+	tdat := []byte{1, 0xFF, 0xFE} // 带 BOM 的 UTF-16LE
+	for _, r := range "1407" { tdat = append(tdat, byte(r), 0) }
+	tests := []struct {
+		name string
+		data []byte
+		want []audioTime
+	}{
+		{"v2.4", id3Tag(4, comment, id3Frame(4, "TDRC", id3Text(3, "2019-07-14T09:30:15+02:00")), id3Frame(4, "TDOR", id3Text(3, "2019-07-14 09:30"))), []audioTime{
+			{value: "2019:07:14 09:30:15+02:00", tag: "ID3 TDRC"},
+			{value: "2019:07:14 09:30:00", tag: "ID3 TDOR"},
+		}},
+		{"v2.4 year only", id3Tag(4, id3Frame(4, "TDRC", id3Text(3, "2019"))), nil},
+		{"v2.3", id3Tag(3, id3Frame(3, "COMM", append([]byte{0, 'e', 'n', 'g', 0}, strings.Repeat("x", 200)...)),
+			id3Frame(3, "TYER", id3Text(0, "2019")), id3Frame(3, "TDAT", tdat), id3Frame(3, "TIME", id3Text(0, "0930"))), []audioTime{
+			{value: "2019:07:14 09:30:00", tag: "ID3 TYER+TDAT+TIME"},
+		}},
+		{"v2.3 without time", id3Tag(3, id3Frame(3, "TYER", id3Text(0, "2019")), id3Frame(3, "TDAT", id3Text(0, "1407"))), nil},
+		{"v2.2", append([]byte("ID3\x02\x00\x00\x00\x00\x00\x10"), make([]byte, 16)...), nil},
+	}
+	for _, tt := range tests {
+		if got := readAudioFixture(t, tt.data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// mp4Box 构造一个 MP4 box。
+func mp4Box(boxType string, content ...[]byte) []byte {
+	body := bytes.Join(content, nil)
+	b := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(b, boxType...), body...)
+}
+
+// mvhd 构造 mvhd box：版本 1 使用 64 位的时间和时长，版本 0 使用 32 位。
+func mvhd(version byte, created time.Time) []byte {
+	seconds := uint64(created.Sub(mp4Epoch) / time.Second)
+	b := []byte{version, 0, 0, 0}
+	if version == 1 {
+		b = binary.BigEndian.AppendUint64(b, seconds)
+		b = binary.BigEndian.AppendUint64(b, seconds)
+		b = binary.BigEndian.AppendUint32(b, 1000)
+		b = binary.BigEndian.AppendUint64(b, 60000)
+	} else {
+		b = binary.BigEndian.AppendUint32(b, uint32(seconds))
+		b = binary.BigEndian.AppendUint32(b, uint32(seconds))
+		b = binary.BigEndian.AppendUint32(b, 1000)
+		b = binary.BigEndian.AppendUint32(b, 60000)
+	}
+	return mp4Box("mvhd", append(b, make([]byte, 80)...))
+}
+
+func TestReadMvhdTimes(t *testing.T) {
+	ftyp := mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00M4A mp42isom"))
+	// 使用 64 位长度的 free box
+	large := append([]byte{0, 0, 0, 1, 'f', 'r', 'e', 'e', 0, 0, 0, 0, 0, 0, 0, 24}, make([]byte, 8)...)
+	tests := []struct {
+		name string
+		data []byte
+		want []audioTime
+	}{
+		{"version 0", bytes.Join([][]byte{ftyp, mp4Box("moov", mvhd(0, time.Date(2019, 7, 14, 7, 30, 15, 0, time.UTC)))}, nil), []audioTime{
+			{value: "2019:07:14 07:30:15Z", tag: "MP4 mvhd", utc: true},
+		}},
+		// 2040 年之后的时间超出 32 位
+		{"version 1", bytes.Join([][]byte{ftyp, large, mp4Box("moov", mp4Box("udta"), mvhd(1, time.Date(2045, 3, 1, 12, 0, 0, 0, time.UTC)))}, nil), []audioTime{
+			{value: "2045:03:01 12:00:00Z", tag: "MP4 mvhd", utc: true},
+		}},
+		{"unset", bytes.Join([][]byte{ftyp, mp4Box("moov", mvhd(0, mp4Epoch))}, nil), nil},
+		{"no moov", bytes.Join([][]byte{ftyp, mp4Box("mdat", make([]byte, 32))}, nil), nil},
+	}
+	for _, tt := range tests {
+		if got := readAudioFixture(t, tt.data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// riffChunk 构造一个 RIFF 块，长度为奇数时补一个填充字节。
+func riffChunk(id string, content []byte) []byte {
+	b := binary.LittleEndian.AppendUint32([]byte(id), uint32(len(content)))
+	b = append(b, content...)
+	if len(content)%2 == 1 { b = append(b, 0) }
+	return b
+}
+
+// bext 构造 bext 块的内容，date 和 clock 原样写入 OriginationDate 和 OriginationTime。
+func bext(date, clock string) []byte {
+	b := make([]byte, 602)
+	copy(b[0:], "Field recording")
+	copy(b[bextOriginationOffset:], date)
+	copy(b[bextOriginationOffset+10:], clock)
+	return b
+}
+
+func TestReadBextTimes(t *testing.T) {
+	wave := func(chunks ...[]byte) []byte {
+		body := append([]byte("WAVE"), bytes.Join(chunks, nil)...)
+		return append(binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body))), body...)
+	}
+	fmtChunk := riffChunk("fmt ", make([]byte, 16))
+	// 长度为奇数的块之后有一个填充字节，跳过时必须算上
+	odd := riffChunk("LIST", []byte("INFOx"))
+	tests := []struct {
+		name string
+		data []byte
+		want []audioTime
+	}{
+		{"after odd chunk", wave(fmtChunk, odd, riffChunk("bext", bext("2019-07-14", "09:30:15")), riffChunk("data", make([]byte, 8))), []audioTime{
+			{value: "2019:07:14 09:30:15", tag: "BWF OriginationDate+OriginationTime"},
+		}},
+		{"other separators", wave(fmtChunk, riffChunk("bext", bext("2019/07/14", "09.30.15"))), []audioTime{
+			{value: "2019:07:14 09:30:15", tag: "BWF OriginationDate+OriginationTime"},
+		}},
+		{"empty", wave(fmtChunk, riffChunk("bext", bext("", ""))), nil},
+		{"no bext", wave(fmtChunk, odd, riffChunk("data", make([]byte, 8))), nil},
+	}
+	for _, tt := range tests {
+		if got := readAudioFixture(t, tt.data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// vorbisComment 构造 Vorbis 注释结构。
+func vorbisComment(vendor string, comments ...string) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	b = append(b, vendor...)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(comments)))
+	for _, c := range comments {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(c)))
+		b = append(b, c...)
+	}
+	return b
+}
+
+// oggPages 把数据包按 Ogg 的分段规则（lacing）切成段，每页最多 maxSegments 段，数据包可以跨页。
+func oggPages(maxSegments int, packets ...[]byte) []byte {
+	var lacing []byte
+	for _, p := range packets {
+		lacing = append(lacing, bytes.Repeat([]byte{255}, len(p)/255)...)
+		lacing = append(lacing, byte(len(p)%255))
+	}
+	data := bytes.Join(packets, nil)
+	var out []byte
+	for seq := 0; len(lacing) > 0; seq++ {
+		n := min(maxSegments, len(lacing))
+		header := append([]byte("OggS"), 0, 0)
+		header = append(header, make([]byte, 8)...) // 颗粒位置
+		header = binary.LittleEndian.AppendUint32(header, 1)
+		header = binary.LittleEndian.AppendUint32(header, uint32(seq))
+		header = append(header, 0, 0, 0, 0, byte(n)) // CRC 不校验
+		out = append(append(out, header...), lacing[:n]...)
+		length := 0
+		for _, l := range lacing[:n] { length += int(l) }
+		out = append(out, data[:length]...)
+		lacing, data = lacing[n:], data[length:]
+	}
+	return out
+}
+
+func TestReadVorbisCommentTimes(t *testing.T) {
+	opusHead := []byte("OpusHead\x01\x02\x38\x01\x80\xbb\x00\x00\x00\x00\x00")
+	// 超过 255 字节的注释包由多个段组成，每页只放两段时跨越三页
+	opusTags := append([]byte("OpusTags"), vorbisComment("libopus 1.3", "TITLE="+strings.Repeat("x", 600), "date=2019-07-14T09:30:15+0200")...)
+	vorbisHead := append([]byte("\x01vorbis"), make([]byte, 23)...)
+	vorbisTags := append([]byte("\x03vorbis"), vorbisComment("Xiph.Org libVorbis", "DATE=2019-07-14 09:30", "ARTIST=x")...)
+	vorbisTags = append(vorbisTags, 1) // 帧标志
+	streamInfo := append([]byte{0, 0, 0, 34}, make([]byte, 34)...)
+	flacComment := vorbisComment("reference libFLAC", "DATE=2019-07-14T09:30:15")
+	flacBlock := append([]byte{0x84, 0, byte(len(flacComment) >> 8), byte(len(flacComment))}, flacComment...)
+	tests := []struct {
+		name string
+		data []byte
+		want []audioTime
+	}{
+		{"Opus across pages", oggPages(2, opusHead, opusTags), []audioTime{{value: "2019:07:14 09:30:15+02:00", tag: "Opus DATE"}}},
+		{"Vorbis", oggPages(255, vorbisHead, vorbisTags), []audioTime{{value: "2019:07:14 09:30:00", tag: "Vorbis DATE"}}},
+		{"Vorbis date only", oggPages(255, vorbisHead, append([]byte("\x03vorbis"), vorbisComment("x", "DATE=2019-07-14")...)), nil},
+		{"FLAC", bytes.Join([][]byte{[]byte("fLaC"), streamInfo, flacBlock}, nil), []audioTime{{value: "2019:07:14 09:30:15", tag: "FLAC DATE"}}},
+	}
+	for _, tt := range tests {
+		if got := readAudioFixture(t, tt.data); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// paxSourceDirKey 是写入备份归档全局 PAX 头的自定义记录，保存被备份目录的绝对路径，
+// 使 restore 命令在未指定目标目录时也能知道应当还原到哪里。
+const paxSourceDirKey = "MEDIASORTER.source_dir"
+
+// paxXattrPrefix 是 PAX 头中保存扩展属性的记录前缀，与 GNU tar 和 bsdtar 兼容。
+const paxXattrPrefix = "SCHILY.xattr."
+
+// fileIdentity 唯一标识一个文件（设备号 + inode 号），用于识别同一文件的多个硬链接。
+type fileIdentity struct{ dev, ino uint64 }
+
+// backupTimeLayout 是备份文件名中时间戳部分的格式。
+const backupTimeLayout = "20060102_150405"
+
+// createBackup 将源目录打包为 tar.gz（快照模式下则镜像为一个目录），同时为每个条目生成清单。
+// 选择性模式下只打包计划中会被修改的文件。
+// 归档写完后会显式关闭并同步到磁盘，任何一步出错都视为备份失败。
+func createBackup(sourceDir, backupDir, mode string, plan []*plannedFile) (string, *backupManifest, error) {
+	if err := os.MkdirAll(backupDir, 0755); err != nil { return "", nil, fmt.Errorf("could not create backup directory: %w", err) }
+	var err error
+	createdAt := time.Now()
+	backupFilename := fmt.Sprintf("backup_%s_%s", filepath.Base(sourceDir), createdAt.Format(backupTimeLayout))
+	if mode != backupModeSnapshot { backupFilename += ".tar.gz" }
+	backupFilepath := filepath.Join(backupDir, backupFilename)
+	fmt.Printf("Backing up '%s' to '%s'...\n", sourceDir, backupFilepath)
+
+	manifest := &backupManifest{Version: manifestVersion, SourceDir: sourceDir, Archive: backupFilename, Mode: mode, CreatedAt: createdAt}
+	var include map[string]bool
+	if mode == backupModeSelective {
+		include = make(map[string]bool)
+		for _, pf := range plan {
+			if pf.willChange() { include[filepath.Clean(pf.Path)] = true }
+		}
+		fmt.Printf("Selective backup: %d files will be modified.\n", len(include))
+	}
+	if mode == backupModeSnapshot {
+		err = writeSnapshot(backupFilepath, sourceDir, backupDir, manifest)
+	} else {
+		err = writeBackupArchive(backupFilepath, sourceDir, backupDir, include, manifest)
+	}
+	if err != nil { return backupFilepath, nil, err }
+	if err := writeManifest(manifestPathFor(backupFilepath), manifest); err != nil {
+		return backupFilepath, nil, fmt.Errorf("could not write backup manifest: %w", err)
+	}
+	return backupFilepath, manifest, nil
+}
+
+// writeBackupArchive 写出归档。include 为 nil 时打包整个目录，否则只打包其中列出的普通文件。
+func writeBackupArchive(backupFilepath, sourceDir, backupDir string, include map[string]bool, manifest *backupManifest) (err error) {
+	file, err := os.Create(backupFilepath); if err != nil { return fmt.Errorf("could not create backup file: %w", err) }
+	gw := gzip.NewWriter(file)
+	tw := tar.NewWriter(gw)
+	// 按 tar -> gzip -> 文件的顺序关闭，并且不能忽略任何一个 Close 的错误：
+	// 它们负责写出最后的数据块和校验和，出错就意味着归档被截断。
+	defer func() {
+		closeErrs := []error{tw.Close(), gw.Close()}
+		if err == nil { closeErrs = append(closeErrs, file.Sync()) }
+		closeErrs = append(closeErrs, file.Close())
+		for _, closeErr := range closeErrs {
+			if err == nil && closeErr != nil { err = fmt.Errorf("could not finalize backup file: %w", closeErr) }
+		}
+	}()
+
+	// 记录源目录，供 restore 命令使用
+	if err := tw.WriteHeader(&tar.Header{
+		Typeflag:   tar.TypeXGlobalHeader,
+		PAXRecords: map[string]string{paxSourceDirKey: sourceDir},
+	}); err != nil { return err }
+
+	absBackupDir, err := filepath.Abs(backupDir)
+	if err != nil {
+		return fmt.Errorf("could not resolve absolute path for backup directory: %w", err)
+	}
+	hardlinks := make(map[fileIdentity]string)
+	return filepath.Walk(sourceDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil { return err }
+		if filepath.Clean(path) == filepath.Clean(absBackupDir) {
+			log.Printf("INFO: Skipping backup directory itself: %s", path)
+			return filepath.SkipDir
+		}
+		if include != nil && !include[filepath.Clean(path)] { return nil }
+		relPath, err := filepath.Rel(sourceDir, path); if err != nil { return err }
+		header, err := fileHeader(path, info)
+		if err != nil { return fmt.Errorf("could not read attributes of '%s': %w", relPath, err) }
+		// tar 头中的路径统一使用 '/' 分隔，保证跨平台还原
+		header.Name = filepath.ToSlash(relPath)
+		entry := newManifestEntry(header.Name, info)
+		entry.LinkTarget = header.Linkname
+
+		// 同一文件的其余硬链接只记录为指向第一次出现的路径，不重复保存内容
+		if info.Mode().IsRegular() {
+			if id, nlink, ok := fileIdentityOf(info); ok && nlink > 1 {
+				if first, seen := hardlinks[id]; seen {
+					header.Typeflag, header.Linkname, header.Size = tar.TypeLink, first, 0
+					entry.HardlinkTo = first
+					if err := tw.WriteHeader(header); err != nil { return err }
+					manifest.Entries = append(manifest.Entries, entry)
+					return nil
+				}
+				hardlinks[id] = header.Name
+			}
+		}
+		if err := tw.WriteHeader(header); err != nil { return err }
+		if !info.Mode().IsRegular() { manifest.Entries = append(manifest.Entries, entry); return nil }
+
+		f, err := os.Open(path); if err != nil { return err }; defer f.Close()
+		hasher := sha256.New()
+		n, err := io.Copy(io.MultiWriter(tw, hasher), f)
+		if err != nil { return fmt.Errorf("could not archive '%s': %w", relPath, err) }
+		if n != info.Size() { return fmt.Errorf("'%s' changed size while being backed up", relPath) }
+		entry.SHA256 = hex.EncodeToString(hasher.Sum(nil))
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+}
+
+// fileHeader 为一个条目构造完整的 tar 头：符号链接的真实目标、属主（由 tar.FileInfoHeader 填写）、
+// 纳秒精度的时间戳以及扩展属性。使用 PAX 格式，否则 tar.Writer 会把时间截断到秒。
+func fileHeader(path string, info fs.FileInfo) (*tar.Header, error) {
+	link := ""
+	if info.Mode()&fs.ModeSymlink != 0 {
+		var err error
+		if link, err = os.Readlink(path); err != nil { return nil, err }
+	}
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil { return nil, err }
+	header.Format = tar.FormatPAX
+	if info.Mode()&fs.ModeSymlink != 0 { return header, nil }
+
+	xattrs, err := readXattrs(path)
+	if err != nil { return nil, err }
+	for name, value := range xattrs {
+		if header.PAXRecords == nil { header.PAXRecords = make(map[string]string) }
+		header.PAXRecords[paxXattrPrefix+name] = value
+	}
+	return header, nil
+}
+
+// headerXattrs 从 tar 头的 PAX 记录中取出扩展属性。
+func headerXattrs(hdr *tar.Header) map[string]string {
+	var xattrs map[string]string
+	for key, value := range hdr.PAXRecords {
+		if !strings.HasPrefix(key, paxXattrPrefix) { continue }
+		if xattrs == nil { xattrs = make(map[string]string) }
+		xattrs[strings.TrimPrefix(key, paxXattrPrefix)] = value
+	}
+	return xattrs
+}
+
+// backupArchive 描述了备份目录中的一个备份归档。
+type backupArchive struct {
+	Name       string    // 文件名，如 backup_photos_20240501_120000.tar.gz；快照目录没有扩展名
+	Path       string    // 完整路径
+	Size       int64     // 归档大小（字节）；快照只计算独占空间的文件，见 snapshotSize
+	IsSnapshot bool      // 是否为快照目录
+	SourceName string    // 从文件名中解析出的源目录名
+	SourceDir  string    // 源目录的绝对路径，来自归档的全局 PAX 头或快照的清单；读不到时为空
+	CreatedAt  time.Time // 从文件名中解析出的创建时间
+}
+
+// listBackups 返回备份目录中所有由本程序创建的备份归档，按创建时间升序排列。
+func listBackups(backupDir string) ([]backupArchive, error) {
+	entries, err := os.ReadDir(backupDir)
+	if err != nil { return nil, err }
+
+	var archives []backupArchive
+	for _, entry := range entries {
+		sourceName, createdAt, ok := parseBackupName(entry.Name())
+		if !ok || entry.IsDir() != !strings.HasSuffix(entry.Name(), ".tar.gz") { continue }
+		info, err := entry.Info()
+		if err != nil { continue }
+		archive := backupArchive{
+			Name:       entry.Name(),
+			Path:       filepath.Join(backupDir, entry.Name()),
+			Size:       info.Size(),
+			IsSnapshot: entry.IsDir(),
+			SourceName: sourceName,
+			CreatedAt:  createdAt,
+		}
+		if archive.IsSnapshot {
+			archive.Size = 0
+			if m, err := readManifest(archive.Path); err == nil && m != nil { archive.Size, archive.SourceDir = snapshotSize(archive.Path, m), m.SourceDir }
+		} else {
+			archive.SourceDir = archiveSourceDir(archive.Path)
+		}
+		archives = append(archives, archive)
+	}
+	sort.SliceStable(archives, func(i, j int) bool { return archives[i].CreatedAt.Before(archives[j].CreatedAt) })
+	return archives, nil
+}
+
+// archiveSourceDir 从归档开头的全局 PAX 头中读取源目录，只解压第一个条目。读不到时返回空字符串。
+func archiveSourceDir(archivePath string) string {
+	file, err := os.Open(archivePath)
+	if err != nil { return "" }
+	defer file.Close()
+	gr, err := gzip.NewReader(file)
+	if err != nil { return "" }
+	defer gr.Close()
+	hdr, err := tar.NewReader(gr).Next()
+	if err != nil || hdr.Typeflag != tar.TypeXGlobalHeader { return "" }
+	return hdr.PAXRecords[paxSourceDirKey]
+}
+
+// sourceKey 返回保留策略分组使用的源目录：优先使用完整路径，使同名的不同目录互不挤占名额。
+func (a backupArchive) sourceKey() string {
+	if a.SourceDir != "" { return a.SourceDir }
+	return a.SourceName
+}
+
+// failedBackupSuffix 标记没有通过校验的备份。这样的备份会被保留以便检查，但不算作备份，也不参与保留策略。
+const failedBackupSuffix = ".failed"
+
+// markBackupFailed 为没有通过校验的备份（及其清单）加上 failedBackupSuffix 后缀，返回新路径。
+func markBackupFailed(archivePath string) (string, error) {
+	failedPath := archivePath + failedBackupSuffix
+	if err := os.Rename(archivePath, failedPath); err != nil { return "", err }
+	if err := os.Rename(manifestPathFor(archivePath), manifestPathFor(failedPath)); err != nil && !os.IsNotExist(err) { return failedPath, err }
+	return failedPath, nil
+}
+
+// parseBackupName 解析 backup_<目录名>_<YYYYMMDD>_<HHMMSS>[.tar.gz] 形式的备份名称。
+// 目录名本身可能包含下划线，因此时间戳从末尾取。
+func parseBackupName(name string) (sourceName string, createdAt time.Time, ok bool) {
+	if !strings.HasPrefix(name, "backup_") || strings.HasSuffix(name, ".json") || strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, failedBackupSuffix) { return "", time.Time{}, false }
+	core := strings.TrimSuffix(strings.TrimPrefix(name, "backup_"), ".tar.gz")
+	if len(core) < len(backupTimeLayout)+2 { return "", time.Time{}, false }
+	stamp := core[len(core)-len(backupTimeLayout):]
+	t, err := time.ParseInLocation(backupTimeLayout, stamp, time.Local)
+	if err != nil || core[len(core)-len(backupTimeLayout)-1] != '_' { return "", time.Time{}, false }
+	return core[:len(core)-len(backupTimeLayout)-1], t, true
+}
+
+// formatSize 将字节数格式化为便于阅读的字符串。
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit { return fmt.Sprintf("%d B", n) }
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit { div *= unit; exp++ }
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
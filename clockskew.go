@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// clockCorrection 是配置文件中的一条相机时钟修正规则。make、model、serial 中不为空的字段必须全部匹配（不区分大小写），
+// from 和 to 是可选的相机时钟读数范围（含两端）。offset 是 Go 时长格式，如 "+1h30m" 或 "-45s"，表示相机时钟慢了多少。
+type clockCorrection struct {
+	Make            string `json:"make"`
+	Model           string `json:"model"`
+	Serial          string `json:"serial"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Offset          string `json:"offset"`
+	CorrectMetadata bool   `json:"correct_metadata"` // 同时改写文件中已有的时间标签，而不只是补全空标签
+}
+
+// clockRule 是解析后的修正规则。from 和 to 以 UTC 表示相机的钟面时间，to 不含；零值表示不限。
+type clockRule struct {
+	make, model, serial string
+	from, to            time.Time
+	offset              time.Duration
+	correctMetadata     bool
+}
+
+// 改写已有时间标签时，会在 XMP 编辑历史（xmpMM:History）的 softwareAgent 中记录 "media-sorter <说明>"。
+// 已经修正、平移或人工指定过时间的文件带有这个标记，再次运行时时钟修正规则不会重复生效。
+const (
+	historyAgent           = "media-sorter"
+	historyClockCorrection = "clock correction" // 由 clock_corrections 规则改写
+	historyShift           = "shift"            // 由 shift 命令改写
+	historyManualDate      = "manual date"      // 由 review 命令按用户输入的日期改写
+	historyTag             = "XMP-xmpMM:HistorySoftwareAgent"
+)
+
+// parseClockCorrections 解析并校验配置中的所有修正规则。
+func parseClockCorrections(entries []clockCorrection) ([]clockRule, error) {
+	var rules []clockRule
+	for i, entry := range entries {
+		rule, err := parseClockCorrection(entry)
+		if err != nil { return nil, fmt.Errorf("invalid clock correction %d: %w", i+1, err) }
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseClockCorrection(entry clockCorrection) (clockRule, error) {
+	rule := clockRule{
+		make:            strings.TrimSpace(entry.Make),
+		model:           strings.TrimSpace(entry.Model),
+		serial:          strings.TrimSpace(entry.Serial),
+		correctMetadata: entry.CorrectMetadata,
+	}
+	if rule.make == "" && rule.model == "" && rule.serial == "" { return rule, fmt.Errorf("at least one of 'make', 'model' or 'serial' is required") }
+
+	var err error
+	if rule.offset, err = time.ParseDuration(strings.TrimPrefix(strings.TrimSpace(entry.Offset), "+")); err != nil { return rule, fmt.Errorf("offset: %w", err) }
+	if rule.offset == 0 { return rule, fmt.Errorf("offset must not be zero") }
+	if entry.From != "" {
+		if rule.from, _, err = parseWallTime(entry.From); err != nil { return rule, fmt.Errorf("from: %w", err) }
+	}
+	if entry.To != "" {
+		var dateOnly bool
+		if rule.to, dateOnly, err = parseWallTime(entry.To); err != nil { return rule, fmt.Errorf("to: %w", err) }
+		if dateOnly {
+			rule.to = rule.to.AddDate(0, 0, 1)
+		} else {
+			rule.to = rule.to.Add(time.Second)
+		}
+		if !rule.from.Before(rule.to) { return rule, fmt.Errorf("'from' (%s) is after 'to' (%s)", entry.From, entry.To) }
+	}
+	return rule, nil
+}
+
+// matches 判断规则是否适用于这台相机在钟面时间 wall 拍摄的文件。
+func (r clockRule) matches(meta mediaMetadata, wall time.Time) bool {
+	if r.make != "" && !strings.EqualFold(r.make, meta.Get("Make")) { return false }
+	if r.model != "" && !strings.EqualFold(r.model, meta.Get("Model")) { return false }
+	if r.serial != "" && !strings.EqualFold(r.serial, meta.Get("SerialNumber")) { return false }
+	if !r.from.IsZero() && wall.Before(r.from) { return false }
+	if !r.to.IsZero() && !wall.Before(r.to) { return false }
+	return true
+}
+
+// describeOffset 把修正量格式化为带符号的形式，如 "+1h30m0s"。
+func describeOffset(d time.Duration) string {
+	if d > 0 { return "+" + d.String() }
+	return d.String()
+}
+
+// findClockRule 返回第一条适用于该文件的规则。元数据中已经记录过修正、平移或人工指定日期的文件不再匹配任何规则（第三个返回值为 true）。
+// wall 是相机的钟面读数，即元数据中记录的时间本身。
+func findClockRule(rules []clockRule, meta mediaMetadata, wall time.Time) (clockRule, bool, bool) {
+	if len(rules) == 0 { return clockRule{}, false, false }
+	history := meta.Get(historyTag)
+	for _, action := range []string{historyClockCorrection, historyShift, historyManualDate} {
+		if strings.Contains(history, historyAgent+" "+action) { return clockRule{}, false, true }
+	}
+	for _, rule := range rules {
+		if rule.matches(meta, wall) { return rule, true, false }
+	}
+	return clockRule{}, false, false
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// confidence 是权威时间的可信程度，数值越大越可信。
+type confidence int
+
+const (
+	confidenceMtime    confidence = iota // 文件修改时间，可能只是复制或下载的时间
+	confidenceFolder                     // 所在文件夹名中的日期，通常只精确到日、月或年
+	confidenceSidecar                    // 旁车文件（XMP、Google Takeout JSON）
+	confidenceFilename                   // 文件名中的日期时间
+	confidenceNaive                      // 元数据中不带时区的时间
+	confidenceOffset                     // 元数据中带时区偏移的时间
+)
+
+// confidenceNames 是各级别在配置和输出中使用的名称。
+var confidenceNames = map[confidence]string{
+	confidenceMtime:    "mtime",
+	confidenceFolder:   "folder",
+	confidenceSidecar:  "sidecar",
+	confidenceFilename: "filename",
+	confidenceNaive:    "metadata",
+	confidenceOffset:   "offset",
+}
+
+func (c confidence) String() string { return confidenceNames[c] }
+
+// parseConfidence 把配置中的级别名称转换为 confidence。
+func parseConfidence(name string) (confidence, error) {
+	for c, n := range confidenceNames {
+		if strings.EqualFold(n, name) { return c, nil }
+	}
+	return 0, fmt.Errorf("unknown confidence level '%s', expected one of: offset, metadata, filename, sidecar, folder, mtime", name)
+}
+
+// 低可信度文件的处理方式。
+const (
+	reviewActionMove = "move" // 原名移入复核目录，不修改元数据和 mtime
+	reviewActionSkip = "skip" // 原地保留，完全不处理
+)
+
+// lowConfidenceConfig 是配置文件中低可信度文件的处理规则。
+type lowConfidenceConfig struct {
+	Threshold string `json:"threshold"` // 低于这一级别的文件不按其时间重命名；为空表示不启用
+	Action    string `json:"action"`    // "move" 或 "skip"
+	Folder    string `json:"folder"`    // 复核目录，相对于目标目录
+}
+
+// reviewFolder 返回启用了移入复核目录时的复核目录（相对于目标目录），否则返回空字符串。
+// 只有这时复核目录才由本程序管理，常规运行才会跳过它。
+func (c lowConfidenceConfig) reviewFolder() string {
+	if c.Threshold == "" || c.Action != reviewActionMove { return "" }
+	return c.Folder
+}
+
+// reviewPolicy 是解析后的低可信度规则。
+type reviewPolicy struct {
+	enabled   bool
+	threshold confidence
+	action    string
+	folder    string
+}
+
+// newReviewPolicy 校验配置中的低可信度规则。
+func newReviewPolicy(cfg lowConfidenceConfig) (reviewPolicy, error) {
+	p := reviewPolicy{action: cfg.Action, folder: cfg.Folder}
+	if cfg.Threshold == "" { return p, nil }
+	var err error
+	if p.threshold, err = parseConfidence(cfg.Threshold); err != nil { return p, fmt.Errorf("invalid 'low_confidence.threshold': %w", err) }
+	if p.action != reviewActionMove && p.action != reviewActionSkip {
+		return p, fmt.Errorf("invalid 'low_confidence.action': '%s', expected '%s' or '%s'", p.action, reviewActionMove, reviewActionSkip)
+	}
+	if p.folder == "" || filepath.IsAbs(p.folder) || strings.HasPrefix(filepath.Clean(p.folder), "..") {
+		return p, fmt.Errorf("invalid 'low_confidence.folder': '%s', expected a directory name inside the target directory", p.folder)
+	}
+	p.enabled = true
+	return p, nil
+}
+
+// needsReview 报告该可信度的文件是否应当交给人工复核。
+func (p reviewPolicy) needsReview(c confidence) bool {
+	return p.enabled && c < p.threshold
+}
+
+// describeAction 返回低可信度文件处理方式的说明，用于输出。
+func (p reviewPolicy) describeAction() string {
+	if p.action == reviewActionSkip { return "left untouched" }
+	return fmt.Sprintf("moved to '%s' for review", p.folder)
+}
+
+// quarantinePath 返回文件在复核目录中的位置，保留其相对于目标目录的子目录结构，便于按文件夹复核。
+func (p reviewPolicy) quarantinePath(rootDir, path string) string {
+	rel, err := filepath.Rel(rootDir, path)
+	if err != nil { rel = filepath.Base(path) }
+	return filepath.Join(rootDir, p.folder, rel)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// deviceRule 是配置文件中的一条设备规则：用别名作为文件名前缀，或者把这台设备的文件移入单独的文件夹，
+// 便于区分同一场合里多台同型号手机拍摄的文件。make、model、serial 中不为空的字段必须全部匹配（不区分大小写）。
+type deviceRule struct {
+	Make   string `json:"make"`
+	Model  string `json:"model"`
+	Serial string `json:"serial"`
+	Prefix string `json:"prefix"` // 代替 image_prefix / video_prefix 的前缀（设备别名），如 "ALEX"
+	Folder string `json:"folder"` // 非空时移入目标目录下的这个文件夹（保留子目录结构）
+}
+
+// checkDeviceRules 校验配置中的设备规则。
+func checkDeviceRules(rules []deviceRule) error {
+	for i, rule := range rules {
+		switch {
+		case strings.TrimSpace(rule.Make) == "" && strings.TrimSpace(rule.Model) == "" && strings.TrimSpace(rule.Serial) == "":
+			return fmt.Errorf("invalid device rule %d: at least one of 'make', 'model' or 'serial' is required", i+1)
+		case rule.Prefix == "" && rule.Folder == "":
+			return fmt.Errorf("invalid device rule %d: at least one of 'prefix' or 'folder' is required", i+1)
+		case rule.Prefix != "" && !prefixPattern.MatchString(rule.Prefix):
+			return fmt.Errorf("invalid device rule %d: 'prefix' '%s' may only contain letters, digits and hyphens", i+1, rule.Prefix)
+		case rule.Folder != "" && !isInsideFolder(rule.Folder):
+			return fmt.Errorf("invalid device rule %d: 'folder' '%s' is not a directory name inside the target directory", i+1, rule.Folder)
+		}
+	}
+	return nil
+}
+
+// findDeviceRule 返回第一条与文件元数据中的相机匹配的规则。
+func findDeviceRule(rules []deviceRule, meta mediaMetadata) (deviceRule, bool) {
+	cameraMake, cameraModel, serial := meta.Get("Make"), meta.Get("Model"), meta.Get("SerialNumber")
+	for _, rule := range rules {
+		if m := strings.TrimSpace(rule.Make); m != "" && !strings.EqualFold(m, cameraMake) { continue }
+		if m := strings.TrimSpace(rule.Model); m != "" && !strings.EqualFold(m, cameraModel) { continue }
+		if s := strings.TrimSpace(rule.Serial); s != "" && !strings.EqualFold(s, serial) { continue }
+		return rule, true
+	}
+	return deviceRule{}, false
+}
+
+// resolveDeviceAlias 在解析文件名中的时间之前换上匹配的设备别名，这样以别名开头的标准文件名才会被识别为本程序之前生成的名字。
+// 文件名已经带有预设的前缀时（之前的运行生成的 IMG_ 名字、截图前缀）保留它，别名由 applyDeviceRule 在之后换上。
+func resolveDeviceAlias(pf *plannedFile, rules []deviceRule) {
+	if pf.IsAudio || ownFilenamePrefix(pf.Path) == pf.Prefix { return }
+	if rule, ok := findDeviceRule(rules, pf.Meta); ok && rule.Prefix != "" { pf.Prefix = rule.Prefix }
+}
+
+// applyDeviceRule 按匹配的设备规则修改文件的前缀和理想路径。设备信息来自规划时读取的元数据，不再调用 exiftool。
+func applyDeviceRule(pf *plannedFile, rootDir string, rules []deviceRule) {
+	rule, ok := findDeviceRule(rules, pf.Meta)
+	if !ok { return }
+	if rule.Prefix != "" { pf.Prefix = rule.Prefix }
+	dir := filepath.Dir(pf.Path)
+	if rule.Folder != "" { dir = destinationDir(rootDir, rule.Folder, dir) }
+	pf.IdealPath = filepath.Join(dir, generateNewFilename(pf.Time, pf.Prefix, pf.extension, pf.IsAuthoritative))
+	fmt.Printf("  └─ INFO: Device rule matched: %s.\n", describeDevice(pf.Meta))
+}
+
+// describeDevice 返回相机的厂商和型号，用于输出。
+func describeDevice(meta mediaMetadata) string {
+	name := strings.TrimSpace(meta.Get("Make") + " " + meta.Get("Model"))
+	if serial := meta.Get("SerialNumber"); serial != "" { name += " #" + serial }
+	return name
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestResolveDeviceAlias(t *testing.T) {
+	rules := []deviceRule{{Make: "Google", Model: "Pixel 8", Prefix: "ALEX-2"}}
+	pixel := mediaMetadata{"Make": "Google", "Model": "Pixel 8"}
+	tests := []struct {
+		name string
+		meta mediaMetadata
+		want string
+	}{
+		{"PXL_20240101_120000123.jpg", pixel, "ALEX-2"},
+		// 之前的运行已经使用了别名
+		{"ALEX-2_20240101_120000.jpg", pixel, "ALEX-2"},
+		// 配置别名之前生成的名字保留 IMG，它的时间仍然不被当作独立的来源
+		{"IMG_20240101_120000.jpg", pixel, "IMG"},
+		{"IMG_1234.jpg", mediaMetadata{"Make": "Canon"}, "IMG"},
+	}
+	for _, tt := range tests {
+		pf := &plannedFile{Path: tt.name, Prefix: "IMG", IsImage: true, Meta: tt.meta}
+		resolveDeviceAlias(pf, rules)
+		if pf.Prefix != tt.want { t.Errorf("%s: prefix '%s', want '%s'", tt.name, pf.Prefix, tt.want) }
+		if _, _, ok := filenameTime(tt.name, pf.Prefix); ok && ownFilenamePrefix(tt.name) != "" {
+			t.Errorf("%s: the time of a generated name is used as a filename time", tt.name)
+		}
+	}
+}
+
+func TestCheckDeviceRulePrefix(t *testing.T) {
+	for prefix, valid := range map[string]bool{"ALEX": true, "ALEX-2": true, "Pixel8": true, "小明": true, "A B": false, "a/b": false, "ALEX_2": false} {
+		err := checkDeviceRules([]deviceRule{{Make: "Google", Prefix: prefix}})
+		if (err == nil) != valid { t.Errorf("'%s': checkDeviceRules = %v, want valid %v", prefix, err, valid) }
+	}
+}
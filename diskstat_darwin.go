@@ -0,0 +1,13 @@
+package main
+
+import "syscall"
+
+// mntRdonly 是 statfs 返回的挂载标志中表示只读挂载的位 (MNT_RDONLY)。
+const mntRdonly = 0x1
+
+// diskStatus 返回 path 所在文件系统对当前用户可用的空间，以及它是否以只读方式挂载。
+func diskStatus(path string) (free int64, readOnly bool, err error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil { return 0, false, err }
+	return int64(st.Bavail) * int64(st.Bsize), st.Flags&mntRdonly != 0, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux && !darwin && !windows
+
+package main
+
+import "errors"
+
+// diskStatus 在其他平台上不可用，预检会跳过空间检查。
+func diskStatus(path string) (free int64, readOnly bool, err error) {
+	return 0, false, errors.ErrUnsupported
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceExW = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// diskStatus 返回 path 所在卷对当前用户可用的空间。Windows 上只读卷会在权限检查中体现，这里总是返回 false。
+func diskStatus(path string) (free int64, readOnly bool, err error) {
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil { return 0, false, err }
+	var available uint64
+	r, _, callErr := procGetDiskFreeSpaceExW.Call(uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&available)), 0, 0)
+	if r == 0 { return 0, false, callErr }
+	return int64(available), false, nil
+}
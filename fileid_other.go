@@ -0,0 +1,15 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// fileIdentityOf 在没有 inode 概念的平台上不可用，硬链接会被当作独立的文件备份。
+func fileIdentityOf(info fs.FileInfo) (fileIdentity, uint64, bool) {
+	return fileIdentity{}, 0, false
+}
+
+// fileOwnerOf 在没有 POSIX 属主概念的平台上不可用。
+func fileOwnerOf(info fs.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package main
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileIdentityOf 返回文件的设备号和 inode 号，以及它的硬链接数。
+func fileIdentityOf(info fs.FileInfo) (fileIdentity, uint64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok { return fileIdentity{}, 0, false }
+	return fileIdentity{dev: uint64(st.Dev), ino: uint64(st.Ino)}, uint64(st.Nlink), true
+}
+
+// fileOwnerOf 返回文件的属主和属组。
+func fileOwnerOf(info fs.FileInfo) (uid, gid int, ok bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok { return 0, 0, false }
+	return int(st.Uid), int(st.Gid), true
+}
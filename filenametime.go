@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// filenameTimePattern 匹配文件名中常见的日期时间写法，例如：
+//   IMG_20240101_120000、PXL_20240101_120000123、20240101_120000、Screenshot_2024-01-01-12-00-00、
+//   WhatsApp Image 2024-01-01 at 12.00.00、VID-20240101-WA0001（只有日期）。
+var filenameTimePattern = regexp.MustCompile(`(?:^|[^0-9])((?:19|20)\d{2})[-_.]?(\d{2})[-_.]?(\d{2})(?:(?:[-_ T.]|\sat\s)(\d{2})[-_.:]?(\d{2})[-_.:]?(\d{2})(?:[-_.]?(\d{3}))?)?(?:[^0-9]|$)`)
+
+// prefixChars 是文件名前缀（包括设备别名）允许使用的字符：字母、数字和连字符。
+const prefixChars = `[\p{L}\p{N}-]+`
+
+// prefixPattern 匹配一个合法的文件名前缀。
+var prefixPattern = regexp.MustCompile(`^` + prefixChars + `$`)
+
+// ownFilenamePattern 匹配本程序生成的文件名（PREFIX_YYYYMMDD_HHMMSS[_ms][_NN]）。
+// 这类文件名中的时间来自之前的某次运行，可能只是 mtime，不能作为独立的时间来源。
+var ownFilenamePattern = regexp.MustCompile(`^(` + prefixChars + `)_\d{8}_\d{6}(?:_\d{3})?(?:_\d{2})?$`)
+
+// ownFilenamePrefix 返回本程序生成的文件名所用的前缀，其他文件名返回空字符串。
+func ownFilenamePrefix(path string) string {
+	m := ownFilenamePattern.FindStringSubmatch(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	if m == nil { return "" }
+	return m[1]
+}
+
+// filenameTime 从文件名中解析出钟面时间（以 UTC 表示），并报告其中是否只有日期。
+// ownPrefix 是本程序会给这个文件使用的前缀，以它开头的标准文件名会被忽略。
+func filenameTime(path, ownPrefix string) (time.Time, bool, bool) {
+	if p := ownFilenamePrefix(path); p != "" && p == ownPrefix { return time.Time{}, false, false }
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	m := filenameTimePattern.FindStringSubmatch(name)
+	if m == nil { return time.Time{}, false, false }
+	n := make([]int, len(m))
+	for i := 1; i < len(m); i++ { n[i], _ = strconv.Atoi(m[i]) }
+	t := time.Date(n[1], time.Month(n[2]), n[3], n[4], n[5], n[6], n[7]*int(time.Millisecond), time.UTC)
+	// time.Date 会把越界的值进位（如 13 月），进位后与原值不同说明这不是一个日期
+	if t.Month() != time.Month(n[2]) || t.Day() != n[3] || t.Hour() != n[4] || t.Minute() != n[5] || t.Second() != n[6] {
+		return time.Time{}, false, false
+	}
+	return t, m[4] == "", true
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFilenameTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		prefix   string
+		want     time.Time // 零值表示文件名中没有时间
+		dateOnly bool
+	}{
+		{"IMG_20240101_120000.jpg", "VID", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{"PXL_20240101_120000123.jpg", "IMG", time.Date(2024, 1, 1, 12, 0, 0, 123_000_000, time.UTC), false},
+		{"Screenshot_2024-01-01-12-00-00.png", "IMG", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{"WhatsApp Image 2024-01-01 at 12.00.00.jpeg", "IMG", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{"VID-20240101-WA0001.mp4", "VID", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		// 本程序生成的文件名，时间可能只是之前某次运行的 mtime
+		{"IMG_20240101_120000_01.jpg", "IMG", time.Time{}, false},
+		{"ALEX-2_20240101_120000.jpg", "ALEX-2", time.Time{}, false},
+		{"小明_20240101_120000_01.jpg", "小明", time.Time{}, false},
+		{"ALEX-2_20240101_120000.jpg", "IMG", time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), false},
+		{"20241301_120000.jpg", "IMG", time.Time{}, false},
+		{"DSC01234.jpg", "IMG", time.Time{}, false},
+	}
+	for _, tt := range tests {
+		got, dateOnly, ok := filenameTime(tt.name, tt.prefix)
+		if ok != !tt.want.IsZero() || !got.Equal(tt.want) || dateOnly != tt.dateOnly {
+			t.Errorf("%s: filenameTime = %v, %v, %v, want %v, %v", tt.name, got, dateOnly, ok, tt.want, tt.dateOnly)
+		}
+	}
+}
+
+func TestFilenameAndSidecarTimesAreOptIn(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "PXL_20240101_120000.jpg")
+	if err := os.WriteFile(path, nil, 0o644); err != nil { t.Fatal(err) }
+	// Google Takeout：2023-06-01 08:00:00 UTC
+	if err := os.WriteFile(path+".json", []byte(`{"photoTakenTime": {"timestamp": "1685606400"}}`), 0o644); err != nil { t.Fatal(err) }
+	utc := func(time.Time) *time.Location { return time.UTC }
+	tests := []struct {
+		name                    string
+		filenameDates, sidecars bool
+		want                    confidence
+	}{
+		{"disabled", false, false, confidenceMtime},
+		{"filename", true, false, confidenceFilename},
+		{"sidecar", false, true, confidenceSidecar},
+		{"both", true, true, confidenceFilename},
+	}
+	for _, tt := range tests {
+		pf := &plannedFile{Path: path, Prefix: "IMG", IsImage: true, filenameDates: tt.filenameDates, sidecars: tt.sidecars}
+		at, err := getAuthoritativeTime(pf, mediaMetadata{}, "", utc, nil)
+		if err != nil { t.Fatal(err) }
+		if at.Confidence != tt.want { t.Errorf("%s: confidence %s (%s), want %s", tt.name, at.Confidence, at.Source, tt.want) }
+	}
+}
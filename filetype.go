@@ -0,0 +1,228 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// 媒体文件的类别。
+const (
+	kindImage = "image"
+	kindVideo = "video"
+	kindAudio = "audio"
+)
+
+// fileType 是根据文件签名（magic bytes）识别出的文件类型。
+type fileType struct {
+	name    string   // 类型名称，用于输出
+	ext     string   // 规范的扩展名（不带点、小写），更正扩展名时使用
+	kind    string   // 媒体类别
+	accepts []string // 与这种内容相符的扩展名；同一容器格式的不同扩展名（如 mp4 和 mov）都视为相符
+}
+
+// isoVideoExts 是 ISO-BMFF 视频常用的扩展名。MP4、QuickTime 和 3GP 是同一种容器，exiftool 对它们的处理相同，
+// 因此彼此之间不算不符，只有扩展名不属于这一族时才按品牌（brand）给出建议。
+var isoVideoExts = []string{"mp4", "m4v", "mov", "qt", "3gp", "3g2"}
+
+// isoMP4Exts 在 isoVideoExts 之外还包括 MPEG-4 音频的扩展名：许多 .m4a/.m4b 文件使用通用的 isom 或 mp42 主品牌，
+// 仅凭文件头无法与视频区分，这时以扩展名所属的类别为准。
+var isoMP4Exts = append(slices.Clone(isoVideoExts), "m4a", "m4b")
+
+var (
+	typeJPEG = fileType{"JPEG", "jpg", kindImage, []string{"jpg", "jpeg", "jpe", "jfif"}}
+	typePNG  = fileType{"PNG", "png", kindImage, []string{"png"}}
+	typeGIF  = fileType{"GIF", "gif", kindImage, []string{"gif"}}
+	typeWebP = fileType{"WebP", "webp", kindImage, []string{"webp"}}
+	typeHEIC = fileType{"HEIF", "heic", kindImage, []string{"heic", "heif", "hif"}}
+	typeAVIF = fileType{"AVIF", "avif", kindImage, []string{"avif"}}
+	typeMP4  = fileType{"MP4", "mp4", kindVideo, isoMP4Exts}
+	typeMOV  = fileType{"QuickTime", "mov", kindVideo, isoVideoExts}
+	type3GP  = fileType{"3GP", "3gp", kindVideo, isoVideoExts}
+	typeAVI  = fileType{"AVI", "avi", kindVideo, []string{"avi"}}
+	typeMKV  = fileType{"Matroska", "mkv", kindVideo, []string{"mkv", "mk3d", "webm"}}
+	typeWebM = fileType{"WebM", "webm", kindVideo, []string{"webm", "mkv"}}
+	typeM4A  = fileType{"MPEG-4 audio", "m4a", kindAudio, []string{"m4a", "m4b", "mp4"}}
+	typeMP3  = fileType{"MP3", "mp3", kindAudio, []string{"mp3"}}
+	typeWAV  = fileType{"WAV", "wav", kindAudio, []string{"wav", "wave", "bwf"}}
+	typeFLAC = fileType{"FLAC", "flac", kindAudio, []string{"flac"}}
+	typeOgg  = fileType{"Ogg", "ogg", kindAudio, []string{"ogg", "oga", "opus", "ogv"}} // Ogg 中也可能是视频，扩展名相符时按扩展名所属的类别处理
+)
+
+// isoBrands 把 ISO-BMFF 的 ftyp 品牌映射到文件类型。不认识的品牌（如佳能 CR3 的 "crx "）不做判断。
+var isoBrands = map[string]*fileType{
+	"heic": &typeHEIC, "heix": &typeHEIC, "hevc": &typeHEIC, "hevx": &typeHEIC, "heim": &typeHEIC, "heis": &typeHEIC,
+	"avif": &typeAVIF, "avis": &typeAVIF,
+	"qt  ": &typeMOV,
+	"isom": &typeMP4, "iso2": &typeMP4, "iso4": &typeMP4, "iso5": &typeMP4, "iso6": &typeMP4, "mp41": &typeMP4, "mp42": &typeMP4,
+	"avc1": &typeMP4, "M4V ": &typeMP4, "M4VH": &typeMP4, "M4VP": &typeMP4, "dash": &typeMP4, "mmp4": &typeMP4, "XAVC": &typeMP4,
+	"3gp4": &type3GP, "3gp5": &type3GP, "3gp6": &type3GP, "3g2a": &type3GP,
+	"M4A ": &typeM4A, "M4B ": &typeM4A,
+}
+
+// sniffLength 是识别文件类型时读取的文件头长度，足以容纳 ftyp 盒中的兼容品牌和 EBML 头中的 DocType。
+const sniffLength = 64
+
+// sniffFileType 根据文件开头的签名识别文件类型，无法识别时返回 nil。
+func sniffFileType(path string) (*fileType, error) {
+	f, err := os.Open(path)
+	if err != nil { return nil, err }
+	defer f.Close()
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF { return nil, nil }
+	return detectFileType(head[:n]), nil
+}
+
+// detectFileType 识别文件头 head 的类型。
+func detectFileType(head []byte) *fileType {
+	if len(head) < 12 { return nil }
+	switch {
+	case bytes.HasPrefix(head, []byte{0xFF, 0xD8, 0xFF}):
+		return &typeJPEG
+	case bytes.HasPrefix(head, []byte("\x89PNG\r\n\x1a\n")):
+		return &typePNG
+	case bytes.HasPrefix(head, []byte("GIF87a")), bytes.HasPrefix(head, []byte("GIF89a")):
+		return &typeGIF
+	case string(head[0:4]) == "RIFF":
+		switch string(head[8:12]) {
+		case "WEBP":
+			return &typeWebP
+		case "AVI ":
+			return &typeAVI
+		case "WAVE":
+			return &typeWAV
+		}
+	case string(head[4:8]) == "ftyp":
+		return isoFileType(head)
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		// EBML 头中的 DocType 区分 WebM 和 Matroska
+		if bytes.Contains(head, []byte("webm")) { return &typeWebM }
+		return &typeMKV
+	case bytes.HasPrefix(head, []byte("ID3")):
+		return &typeMP3
+	case head[0] == 0xFF && head[1]&0xE0 == 0xE0 && (head[1]>>1)&3 == 1:
+		// 没有 ID3 标签的 MP3 以 MPEG Layer III 帧同步开头
+		return &typeMP3
+	case string(head[0:4]) == "fLaC":
+		return &typeFLAC
+	case string(head[0:4]) == "OggS":
+		return &typeOgg
+	}
+	return nil
+}
+
+// isoFileType 根据 ftyp 盒中的主品牌识别 ISO-BMFF 文件。主品牌是 mif1/msf1 等通用 HEIF 品牌时，再查看兼容品牌。
+func isoFileType(head []byte) *fileType {
+	major := string(head[8:12])
+	if t, ok := isoBrands[major]; ok { return t }
+	if major != "mif1" && major != "msf1" { return nil }
+	size := int(binary.BigEndian.Uint32(head[0:4]))
+	if size > len(head) { size = len(head) }
+	for i := 16; i+4 <= size; i += 4 {
+		if t, ok := isoBrands[string(head[i:i+4])]; ok && t.kind == kindImage { return t }
+	}
+	return &typeHEIC
+}
+
+// matchesExtension 报告扩展名 ext（不带点）是否与这种内容相符（不区分大小写）。
+func (t *fileType) matchesExtension(ext string) bool {
+	return slices.Contains(t.accepts, strings.ToLower(ext))
+}
+
+// supportedExtension 返回与这种内容相符、且在受支持列表中的扩展名（不带点），优先使用规范的扩展名。
+// 例如默认配置不支持 .webm 和 .3gp，WebM 改用 .mkv，3GP 改用 .mp4；没有受支持的扩展名时 ok 为 false。
+func (t *fileType) supportedExtension(imageExtMap, videoExtMap, audioExtMap map[string]bool) (string, bool) {
+	for _, ext := range append([]string{t.ext}, t.accepts...) {
+		if extensionSupported(ext, imageExtMap, videoExtMap, audioExtMap) { return ext, true }
+	}
+	return "", false
+}
+
+// extensionSupported 报告扩展名 ext（不带点）是否在任一受支持列表中（不区分大小写）。
+func extensionSupported(ext string, imageExtMap, videoExtMap, audioExtMap map[string]bool) bool {
+	ext = strings.ToLower(ext)
+	return imageExtMap[ext] || videoExtMap[ext] || audioExtMap[ext]
+}
+
+// classifyFile 确定一个扩展名受支持的文件属于哪个类别：扩展名与内容不符时以内容为准（如扩展名为 .jpg 的 MP4 是视频），
+// 否则按扩展名。扩展名不受支持时 ok 为 false。
+func classifyFile(path string, imageExtMap, videoExtMap, audioExtMap map[string]bool) (kind string, content *fileType, ok bool) {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+	switch {
+	case imageExtMap[ext]:
+		kind = kindImage
+	case videoExtMap[ext]:
+		kind = kindVideo
+	case audioExtMap[ext]:
+		kind = kindAudio
+	default:
+		return "", nil, false
+	}
+	content, err := sniffFileType(path)
+	if err != nil { log.Printf("WARNING: Could not read the file signature of '%s': %v", path, err) }
+	if content != nil && !content.matchesExtension(ext) { kind = content.kind }
+	return kind, content, true
+}
+
+// 扩展名的大小写规则。
+const (
+	extensionCaseKeep  = "keep"  // 保持原扩展名的大小写
+	extensionCaseLower = "lower"
+	extensionCaseUpper = "upper"
+)
+
+// extensionPolicy 是配置文件中统一扩展名的规则，在生成新文件名时应用。
+type extensionPolicy struct {
+	Case     string            `json:"case"`     // "keep"、"lower" 或 "upper"
+	Synonyms map[string]string `json:"synonyms"` // 同义扩展名的替换（不带点），如 {"jpeg": "jpg", "tif": "tiff"}，键不区分大小写
+}
+
+// checkExtensionPolicy 校验配置中的扩展名规则。
+func checkExtensionPolicy(p extensionPolicy) error {
+	switch p.Case {
+	case extensionCaseKeep, extensionCaseLower, extensionCaseUpper:
+	default:
+		return fmt.Errorf("invalid 'extensions.case': '%s', expected '%s', '%s' or '%s'", p.Case, extensionCaseKeep, extensionCaseLower, extensionCaseUpper)
+	}
+	for from, to := range p.Synonyms {
+		if from == "" || to == "" || strings.ContainsAny(from+to, `./\`) {
+			return fmt.Errorf("invalid 'extensions.synonyms' entry '%s': '%s', expected extensions without a dot", from, to)
+		}
+	}
+	return nil
+}
+
+// normalize 按规则转换带点的扩展名 ext。保持大小写时，全大写的扩展名替换为同义词后仍为大写（.JPEG → .JPG）。
+func (p extensionPolicy) normalize(ext string) string {
+	name := strings.TrimPrefix(ext, ".")
+	if name == "" { return ext }
+	for from, to := range p.Synonyms {
+		if !strings.EqualFold(from, name) { continue }
+		if name == strings.ToUpper(name) && name != strings.ToLower(name) { to = strings.ToUpper(to) }
+		name = to
+		break
+	}
+	switch p.Case {
+	case extensionCaseLower:
+		name = strings.ToLower(name)
+	case extensionCaseUpper:
+		name = strings.ToUpper(name)
+	}
+	return "." + name
+}
+
+// warnUnsupportedSynonyms 提示那些把受支持的扩展名替换为不受支持的扩展名的同义词：改名后的文件在之后的运行中不会再被处理。
+func warnUnsupportedSynonyms(p extensionPolicy, imageExtMap, videoExtMap, audioExtMap map[string]bool) {
+	for from, to := range p.Synonyms {
+		if extensionSupported(from, imageExtMap, videoExtMap, audioExtMap) && !extensionSupported(to, imageExtMap, videoExtMap, audioExtMap) {
+			log.Printf("WARNING: 'extensions.synonyms' renames '.%s' files to '.%s', which is not a supported extension; later runs will skip them.", from, to)
+		}
+	}
+}
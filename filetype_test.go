@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// ftyp 构造一个 ftyp 盒：主品牌 major，其后是次版本号和兼容品牌。
+func ftyp(major string, compatible ...string) []byte {
+	b := make([]byte, 16, 16+4*len(compatible))
+	binary.BigEndian.PutUint32(b[0:4], uint32(16+4*len(compatible)))
+	copy(b[4:8], "ftyp")
+	copy(b[8:12], major)
+	for _, c := range compatible { b = append(b, c...) }
+	return append(b, 0, 0, 0, 8, 'm', 'e', 't', 'a')
+}
+
+// ebml 构造一个 EBML 头，DocType 为 docType。
+func ebml(docType string) []byte {
+	b := []byte{0x1A, 0x45, 0xDF, 0xA3, 0x9F, 0x42, 0x86, 0x81, 0x01, 0x42, 0xF7, 0x81, 0x01, 0x42, 0xF2, 0x81, 0x04, 0x42, 0xF3, 0x81, 0x08}
+	b = append(b, 0x42, 0x82, 0x80|byte(len(docType)))
+	return append(b, docType...)
+}
+
+func TestDetectFileType(t *testing.T) {
+	tests := []struct {
+		name string
+		head []byte
+		want *fileType
+	}{
+		{"JPEG", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x2F, 0xFE, 'E', 'x', 'i', 'f', 0, 0}, &typeJPEG},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), &typePNG},
+		{"GIF", []byte("GIF89a\x01\x00\x01\x00\x80\x00"), &typeGIF},
+		{"WebP", []byte("RIFF\x24\x00\x00\x00WEBPVP8 "), &typeWebP},
+		{"AVI", []byte("RIFF\x24\x00\x00\x00AVI LIST"), &typeAVI},
+		{"WAV", []byte("RIFF\x24\x00\x00\x00WAVEfmt "), &typeWAV},
+		// iPhone 的 HEIC 使用主品牌 heic；其他设备常用通用的 mif1，并在兼容品牌中给出 heic
+		{"HEIC", ftyp("heic", "mif1", "heic"), &typeHEIC},
+		{"HEIC mif1", ftyp("mif1", "mif1", "heic"), &typeHEIC},
+		{"AVIF", ftyp("avif", "avif", "mif1", "miaf"), &typeAVIF},
+		{"AVIF mif1", ftyp("mif1", "mif1", "miaf", "avif"), &typeAVIF},
+		{"HEIF mif1", ftyp("mif1", "mif1", "miaf"), &typeHEIC},
+		{"MP4", ftyp("isom", "isom", "iso2", "avc1", "mp41"), &typeMP4},
+		{"QuickTime", ftyp("qt  ", "qt  "), &typeMOV},
+		{"3GP", ftyp("3gp4", "isom", "3gp4"), &type3GP},
+		{"M4A", ftyp("M4A ", "M4A ", "mp42", "isom"), &typeM4A},
+		// 通用品牌的音频文件与视频无法区分，由 classifyFile 按扩展名决定类别
+		{"M4A isom", ftyp("isom", "isom", "iso2", "mp41"), &typeMP4},
+		{"CR3", ftyp("crx ", "crx ", "isom"), nil},
+		{"WebM", ebml("webm"), &typeWebM},
+		{"Matroska", ebml("matroska"), &typeMKV},
+		{"MP3 ID3", []byte("ID3\x04\x00\x00\x00\x00\x01\x00TIT2"), &typeMP3},
+		// MPEG-1 Layer III，128 kbit/s，44.1 kHz，没有 CRC
+		{"MP3 frame", []byte{0xFF, 0xFB, 0x90, 0x64, 0, 0, 0, 0, 0, 0, 0, 0}, &typeMP3},
+		// MPEG-2 Layer III（低采样率）
+		{"MP3 MPEG-2 frame", []byte{0xFF, 0xF3, 0x48, 0xC4, 0, 0, 0, 0, 0, 0, 0, 0}, &typeMP3},
+		// ADTS 格式的 AAC 同样以 0xFFF 开头，但层为 0，不是 MP3
+		{"AAC ADTS", []byte{0xFF, 0xF1, 0x50, 0x80, 0x02, 0x1F, 0xFC, 0, 0, 0, 0, 0}, nil},
+		{"FLAC", []byte("fLaC\x00\x00\x00\x22\x10\x00\x10\x00"), &typeFLAC},
+		{"Ogg", []byte("OggS\x00\x02\x00\x00\x00\x00\x00\x00"), &typeOgg},
+		{"text", []byte("hello, world\n"), nil},
+		{"short", []byte{0xFF, 0xD8, 0xFF}, nil},
+	}
+	for _, tt := range tests {
+		if got := detectFileType(tt.head); got != tt.want {
+			t.Errorf("%s: detectFileType = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSupportedExtension(t *testing.T) {
+	// 默认配置中的受支持列表
+	imageExtMap := sliceToMap([]string{"jpg", "jpeg", "png", "heic", "webp", "gif"})
+	videoExtMap := sliceToMap([]string{"mp4", "mov", "avi", "mkv"})
+	audioExtMap := sliceToMap([]string{"mp3", "m4a", "wav", "flac", "ogg"})
+	tests := []struct {
+		content *fileType
+		want    string // 空字符串表示没有受支持的扩展名
+	}{
+		{&typeHEIC, "heic"},
+		{&typePNG, "png"},
+		{&typeMOV, "mov"},
+		{&type3GP, "mp4"},
+		{&typeWebM, "mkv"},
+		{&typeAVIF, ""},
+	}
+	for _, tt := range tests {
+		got, ok := tt.content.supportedExtension(imageExtMap, videoExtMap, audioExtMap)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("%s: supportedExtension = %q, %v, want %q", tt.content.name, got, ok, tt.want)
+		}
+	}
+}
+
+func TestClassifyFile(t *testing.T) {
+	imageExtMap := sliceToMap([]string{"jpg", "jpeg", "png", "heic", "webp", "gif"})
+	videoExtMap := sliceToMap([]string{"mp4", "mov", "avi", "mkv"})
+	audioExtMap := sliceToMap([]string{"mp3", "m4a", "m4b", "wav", "flac", "ogg"})
+	jpeg := []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x2F, 0xFE, 'E', 'x', 'i', 'f', 0, 0}
+	tests := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		{"song.m4a", ftyp("isom", "isom", "iso2", "mp41"), kindAudio},
+		{"voice.m4a", ftyp("mp42", "mp42", "isom"), kindAudio},
+		{"book.m4b", ftyp("M4B ", "M4B ", "mp42", "isom"), kindAudio},
+		{"clip.mp4", ftyp("isom", "isom", "iso2", "avc1", "mp41"), kindVideo},
+		{"clip.mov", ftyp("qt  ", "qt  "), kindVideo},
+		{"photo.m4a", jpeg, kindImage},
+		{"photo.mp4", jpeg, kindImage},
+	}
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, tt.name)
+		if err := os.WriteFile(path, tt.head, 0o644); err != nil { t.Fatal(err) }
+		if kind, _, ok := classifyFile(path, imageExtMap, videoExtMap, audioExtMap); !ok || kind != tt.want {
+			t.Errorf("%s: classifyFile = %q, %v, want %q", tt.name, kind, ok, tt.want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// folderDatesConfig 是配置文件中从文件夹名推断日期的规则。
+type folderDatesConfig struct {
+	Enabled bool `json:"enabled"` // 没有其他来源时，使用所在文件夹（或上级文件夹）名中的日期
+	Spread  bool `json:"spread"`  // 同一文件夹中的文件按文件名顺序依次相隔一秒，保留扫描或导出的先后顺序
+}
+
+// folderDatePrecision 是文件夹名中日期的精度。
+type folderDatePrecision int
+
+const (
+	folderDateYear folderDatePrecision = iota
+	folderDateMonth
+	folderDateDay
+)
+
+// folderDateHour 是文件夹日期使用的钟面时间。取正午而不是午夜：换算到相差几个小时的时区时不会跨到前一天，
+// 也不会与 2000:01:01 00:00:00 这类占位时间重合。
+const folderDateHour = 12
+
+var (
+	// folderCJKDatePattern 匹配 2019年7月14日、2019年7月。
+	folderCJKDatePattern = regexp.MustCompile(`((?:19|20)\d{2})\s*年(?:\s*(\d{1,2})\s*月(?:\s*(\d{1,2})\s*[日号])?)?`)
+	// folderNumericDatePattern 匹配 2019、2019-07、2019_07_14、2019.07.14、20190714。前后不能紧挨字母或数字，
+	// 以免把型号和尺寸当作年份（"Canon EOS 2000D"、"1920x1080"）。
+	folderNumericDatePattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}])((?:19|20)\d{2})(?:[-_. ]?(\d{2})(?:[-_. ]?(\d{2}))?)?(?:[^\p{L}\p{N}]|$)`)
+	// 以下用于带月份名称的文件夹名，如 "14 July 2019"、"July 14th, 2019"、"Juli 2019"。
+	folderYearPattern      = regexp.MustCompile(`(?:^|[^\p{L}\p{N}])((?:19|20)\d{2})(?:[^\p{L}\p{N}]|$)`)
+	folderDayBeforePattern = regexp.MustCompile(`(?:^|[^0-9])(\d{1,2})(?:st|nd|rd|th|er|\.)?\s*$`)
+	folderDayAfterPattern  = regexp.MustCompile(`^\s*(\d{1,2})(?:st|nd|rd|th)?(?:[^0-9]|$)`)
+	folderWordPattern      = regexp.MustCompile(`\p{L}+`)
+)
+
+// folderMonthNames 是文件夹名中可以识别的月份名称（英、德、法、西、意、葡、荷），不区分大小写。
+var folderMonthNames = map[string]time.Month{
+	"january": 1, "jan": 1, "januar": 1, "janvier": 1, "enero": 1, "gennaio": 1, "janeiro": 1, "januari": 1,
+	"february": 2, "feb": 2, "februar": 2, "février": 2, "fevrier": 2, "febrero": 2, "febbraio": 2, "fevereiro": 2, "februari": 2,
+	"march": 3, "mar": 3, "märz": 3, "maerz": 3, "mars": 3, "marzo": 3, "março": 3, "marco": 3, "maart": 3,
+	"april": 4, "apr": 4, "avril": 4, "abril": 4, "aprile": 4,
+	"may": 5, "mai": 5, "mayo": 5, "maggio": 5, "maio": 5, "mei": 5,
+	"june": 6, "jun": 6, "juni": 6, "juin": 6, "junio": 6, "giugno": 6, "junho": 6,
+	"july": 7, "jul": 7, "juli": 7, "juillet": 7, "julio": 7, "luglio": 7, "julho": 7,
+	"august": 8, "aug": 8, "août": 8, "aout": 8, "agosto": 8, "augustus": 8,
+	"september": 9, "sep": 9, "sept": 9, "septembre": 9, "septiembre": 9, "settembre": 9, "setembro": 9,
+	"october": 10, "oct": 10, "oktober": 10, "octobre": 10, "octubre": 10, "ottobre": 10, "outubro": 10,
+	"november": 11, "nov": 11, "novembre": 11, "noviembre": 11, "novembro": 11,
+	"december": 12, "dec": 12, "dezember": 12, "décembre": 12, "decembre": 12, "diciembre": 12, "dicembre": 12, "dezembro": 12,
+}
+
+// folderDate 在文件夹 dir 及其上级文件夹（直到 root，包括 root 本身）的名字中查找日期，使用离 dir 最近的一个。
+// 返回以 UTC 表示的钟面时间（所在时段第一天的正午）、精度和提供日期的文件夹名。root 为空时不查找。
+func folderDate(dir, root string) (time.Time, folderDatePrecision, string, bool) {
+	if root == "" { return time.Time{}, 0, "", false }
+	for ; ; dir = filepath.Dir(dir) {
+		name := filepath.Base(dir)
+		if t, precision, ok := parseFolderDate(name); ok { return t, precision, name, true }
+		if dir == root || filepath.Dir(dir) == dir { break }
+	}
+	return time.Time{}, 0, "", false
+}
+
+// parseFolderDate 解析一个文件夹名中的日期。
+func parseFolderDate(name string) (time.Time, folderDatePrecision, bool) {
+	if m := folderCJKDatePattern.FindStringSubmatch(name); m != nil { return folderDateFrom(m[1], m[2], m[3]) }
+
+	// 带月份名称：年份必须出现在同一个名字中，日必须紧挨着月份名称（"14 July"、"July 14th"）
+	if year := folderYearPattern.FindStringSubmatch(name); year != nil {
+		for _, loc := range folderWordPattern.FindAllStringIndex(name, -1) {
+			month, ok := folderMonthNames[strings.ToLower(name[loc[0]:loc[1]])]
+			if !ok { continue }
+			day := ""
+			if m := folderDayBeforePattern.FindStringSubmatch(name[:loc[0]]); m != nil {
+				day = m[1]
+			} else if m := folderDayAfterPattern.FindStringSubmatch(name[loc[1]:]); m != nil {
+				day = m[1]
+			}
+			return folderDateFrom(year[1], strconv.Itoa(int(month)), day)
+		}
+	}
+
+	if m := folderNumericDatePattern.FindStringSubmatch(name); m != nil { return folderDateFrom(m[1], m[2], m[3]) }
+	return time.Time{}, 0, false
+}
+
+// folderDateFrom 把年、月、日（月和日可以为空）组合为钟面时间，月或日无效时降低精度。
+func folderDateFrom(yearStr, monthStr, dayStr string) (time.Time, folderDatePrecision, bool) {
+	year, err := strconv.Atoi(yearStr)
+	if err != nil { return time.Time{}, 0, false }
+	month, _ := strconv.Atoi(monthStr)
+	if month < 1 || month > 12 { return time.Date(year, 1, 1, folderDateHour, 0, 0, 0, time.UTC), folderDateYear, true }
+	day, _ := strconv.Atoi(dayStr)
+	t := time.Date(year, time.Month(month), day, folderDateHour, 0, 0, 0, time.UTC)
+	if day < 1 || t.Day() != day { return time.Date(year, time.Month(month), 1, folderDateHour, 0, 0, 0, time.UTC), folderDateMonth, true }
+	return t, folderDateDay, true
+}
+
+// describeFolderDate 按精度格式化文件夹日期，如 "2019"、"2019-07"、"2019-07-14"。
+func describeFolderDate(t time.Time, precision folderDatePrecision) string {
+	switch precision {
+	case folderDateYear:
+		return t.Format("2006")
+	case folderDateMonth:
+		return t.Format("2006-01")
+	}
+	return t.Format("2006-01-02")
+}
+
+// folderDateRange 返回文件夹日期覆盖的日期范围，格式与 review 命令的输入相同。
+func folderDateRange(t time.Time, precision folderDatePrecision) string {
+	from := t.Format("2006-01-02")
+	switch precision {
+	case folderDateYear:
+		return fmt.Sprintf("%s..%d-12-31", from, t.Year())
+	case folderDateMonth:
+		return from + ".." + t.AddDate(0, 1, -1).Format("2006-01-02")
+	}
+	return from
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseFolderDate(t *testing.T) {
+	tests := []struct {
+		name string
+		want string // describeFolderDate 的结果，空字符串表示没有日期
+	}{
+		{"2019", "2019"},
+		{"Scans 1998", "1998"},
+		{"2019-07 Trip to Rome", "2019-07"},
+		{"2019_07_14", "2019-07-14"},
+		{"2019.07.14 Beach", "2019-07-14"},
+		{"20190714", "2019-07-14"},
+		{"20190714_093000", "2019-07-14"},
+		{"2019年7月14日", "2019-07-14"},
+		{"2001年5月 北京", "2001-05"},
+		{"14 July 2019", "2019-07-14"},
+		{"July 14th, 2019", "2019-07-14"},
+		{"Juli 2019", "2019-07"},
+		{"2019-13", "2019"},
+		{"2019-02-30", "2019-02"},
+		// 紧挨字母或数字的四位数不是年份
+		{"Canon EOS 2000D", ""},
+		{"EOS 2000D July", ""},
+		{"1920x1080", ""},
+		{"IMG2019", ""},
+		{"DCIM", ""},
+		{"123456", ""},
+	}
+	for _, tt := range tests {
+		got := ""
+		if tm, precision, ok := parseFolderDate(tt.name); ok { got = describeFolderDate(tm, precision) }
+		if got != tt.want { t.Errorf("parseFolderDate(%q) = %q, want %q", tt.name, got, tt.want) }
+	}
+}
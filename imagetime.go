@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// imageOffsetTagFor 把不带时区的图片时间标签对应到 EXIF 2.31 中记录其时区偏移的标签。
+var imageOffsetTagFor = map[string]string{
+	"Composite:SubSecDateTimeOriginal": "OffsetTimeOriginal",
+	"DateTimeOriginal":                 "OffsetTimeOriginal",
+}
+
+// imageGPSTimeTag 是 GPS 接收机记录的 UTC 时间，由 exiftool 从 GPSDateStamp 和 GPSTimeStamp 合成。
+const imageGPSTimeTag = "Composite:GPSDateTime"
+
+// gpsClockTolerance 是相机时间与 GPS 时间之间可以接受的误差。超出时，如果差值接近整刻钟，
+// 说明相机时钟是对的、只是时区不明，据此推断时区偏移。
+const gpsClockTolerance = 2 * time.Minute
+
+// gpsClockWrongAfter 是认定相机时钟明显不准、改用 GPS 时间的最小差值。它超过了任何两个时区之间的差，
+// 更小的差值通常来自手机写入的过时 GPS 定位，此时保留相机时间，只在来源中注明差异。
+const gpsClockWrongAfter = 24 * time.Hour
+
+// withImageOffset 在图片时间不带时区时，尝试拼接对应的 OffsetTime 标签。
+// 返回拼接后的字符串，以及用于来源说明的标签组合（如 "DateTimeOriginal+OffsetTimeOriginal"）。
+func withImageOffset(tag, dateStr string, meta mediaMetadata) (string, string) {
+	offsetTag, ok := imageOffsetTagFor[tag]
+	if !ok { return dateStr, tag }
+	offset := meta.Get(offsetTag)
+	if _, err := time.Parse("-07:00", offset); err != nil { return dateStr, tag }
+	return dateStr + offset, tag + "+" + offsetTag
+}
+
+// checkAgainstGPS 用 GPS 的 UTC 时间核对图片的时间，返回核对后的结果和说明（没有可用的 GPS 时间时说明为空）。
+// 只有相机时间被确认、推断出时区或被 GPS 时间代替时才设置 GPSChecked，相机时钟修正规则随之不再适用。
+func checkAgainstGPS(at authoritativeTime, meta mediaMetadata, sanity *timestampSanity) (authoritativeTime, string) {
+	gpsTime, err := parseExifTime(meta.Get(imageGPSTimeTag), time.UTC)
+	if err != nil || sanity.check(gpsTime) != "" { return at, "" }
+
+	diff := at.Time.Sub(gpsTime)
+	if diff.Abs() <= gpsClockTolerance {
+		at.GPSChecked = true
+		return at, "confirmed by GPS time"
+	}
+
+	// 不带时区的时间与 GPS 时间相差接近整刻钟：钟面时间正确，只是假定的时区不对
+	zoneDiff := diff.Round(15 * time.Minute)
+	if !at.HasOffset && zoneDiff.Abs() <= 14*time.Hour && (diff - zoneDiff).Abs() <= gpsClockTolerance {
+		wall := wallClock(at.Time)
+		offset := wall.Sub(gpsTime).Round(15 * time.Minute)
+		loc := time.FixedZone("", int(offset.Seconds()))
+		at.Time = time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), loc)
+		at.HasOffset = true
+		at.GPSChecked = true
+		return at, fmt.Sprintf("offset %s from GPS time", at.Time.Format("-07:00"))
+	}
+
+	// 相机时钟明显不准（相差一天以上，或相机时间本身不合理）：改用 GPS 时间，保留原来的时区
+	if reason := sanity.check(at.Time); diff.Abs() >= gpsClockWrongAfter || reason != "" {
+		fmt.Printf("  └─ WARNING: Camera clock differs from GPS time by %s, using GPS time.\n", describeOffset(diff))
+		at.Time = gpsTime.In(at.Time.Location())
+		at.GPSChecked = true
+		return at, fmt.Sprintf("GPS time used, camera clock off by %s", describeOffset(diff))
+	}
+
+	// 差异不大：多半是过时的 GPS 定位，保留相机时间
+	return at, fmt.Sprintf("GPS time differs by %s, kept camera time", describeOffset(diff))
+}
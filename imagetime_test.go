@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckAgainstGPS(t *testing.T) {
+	shanghai := time.FixedZone("", 8*3600)
+	sanity := &timestampSanity{minYear: 1990, bogus: map[string]bool{}, now: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+	tests := []struct {
+		name       string
+		camera     time.Time
+		hasOffset  bool
+		gps        string
+		want       time.Time
+		wantCheck  string
+		gpsChecked bool
+	}{
+		{"no GPS time", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), true, "", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), "", false},
+		{"confirmed", time.Date(2024, 7, 1, 12, 0, 30, 0, shanghai), true, "2024:07:01 04:00:00Z", time.Date(2024, 7, 1, 12, 0, 30, 0, shanghai), "confirmed by GPS time", true},
+		// 钟面时间按 +08:00 解释，GPS 表明拍摄地是 +09:00
+		{"offset inferred", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), false, "2024:07:01 03:00:40Z", time.Date(2024, 7, 1, 12, 0, 0, 0, time.FixedZone("", 9*3600)), "offset +09:00 from GPS time", true},
+		{"stale fix", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), true, "2024:07:01 02:47:10Z", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), "GPS time differs by +1h12m50s, kept camera time", false},
+		{"wrong clock", time.Date(2024, 3, 9, 12, 0, 0, 0, shanghai), true, "2024:07:01 04:00:00Z", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), "GPS time used", true},
+		{"implausible camera time", time.Date(1980, 1, 1, 8, 0, 0, 0, shanghai), true, "2024:07:01 04:00:00Z", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), "GPS time used", true},
+		{"implausible GPS time", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), true, "1970:01:01 00:00:00Z", time.Date(2024, 7, 1, 12, 0, 0, 0, shanghai), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			meta := mediaMetadata{}
+			if tt.gps != "" { meta["GPSDateTime"] = tt.gps }
+			at, check := checkAgainstGPS(authoritativeTime{Time: tt.camera, HasOffset: tt.hasOffset}, meta, sanity)
+			if !at.Time.Equal(tt.want) || at.Time.Format("-07:00") != tt.want.Format("-07:00") {
+				t.Errorf("time = %s, want %s", at.Time.Format(time.RFC3339), tt.want.Format(time.RFC3339))
+			}
+			if !strings.HasPrefix(check, tt.wantCheck) || (tt.wantCheck == "" && check != "") {
+				t.Errorf("check = %q, want prefix %q", check, tt.wantCheck)
+			}
+			if at.GPSChecked != tt.gpsChecked { t.Errorf("GPSChecked = %v, want %v", at.GPSChecked, tt.gpsChecked) }
+		})
+	}
+}
@@ -1,17 +1,10 @@
 package main
 
 import (
-	"archive/tar"
-	"bytes"
-	"compress/gzip"
-	"crypto/rand"
 	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
-	"io/fs"
 	"log"
-	"math/big"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -26,20 +19,54 @@ import (
 var version = "development"
 
 type Config struct {
-	ImagePrefix              string   `json:"image_prefix"`
-	VideoPrefix              string   `json:"video_prefix"`
-	TargetTimezone           string   `json:"target_timezone"`
-	SupportedImageExtensions []string `json:"supported_image_extensions"`
-	SupportedVideoExtensions []string `json:"supported_video_extensions"`
+	ImagePrefix              string                `json:"image_prefix"`
+	VideoPrefix              string                `json:"video_prefix"`
+	AudioPrefix              string                `json:"audio_prefix"`
+	TargetTimezone           string                `json:"target_timezone"`
+	SupportedImageExtensions []string              `json:"supported_image_extensions"`
+	SupportedVideoExtensions []string              `json:"supported_video_extensions"`
+	SupportedAudioExtensions []string              `json:"supported_audio_extensions"`
+	BackupMode               string                `json:"backup_mode"`              // "full"、"selective" 或 "snapshot"
+	Retention                retentionPolicy       `json:"retention"`
+	TimezoneFromGPS          bool                  `json:"timezone_from_gps"`        // 根据 GPS 坐标确定拍摄地时区
+	TimezoneBoundariesFile   string                `json:"timezone_boundaries_file"` // 可选的完整时区边界 GeoJSON，为空时使用内嵌数据
+	FilenameTimezone         string                `json:"filename_timezone"`        // "target" 或 "local"
+	Itinerary                []itineraryEntry      `json:"itinerary"`                // 按日期（和相机）指定拍摄地时区
+	ClockCorrections         []clockCorrection     `json:"clock_corrections"`        // 按相机修正时钟误差
+	VideoTimeConventions     []videoTimeRule       `json:"video_time_conventions"`   // 按设备指定不带时区的视频时间是 UTC 还是当地时间
+	TimestampSanity          timestampSanityConfig `json:"timestamp_sanity"`         // 拒绝占位时间和不可能的时间
+	LowConfidence            lowConfidenceConfig   `json:"low_confidence"`           // 时间可信度过低的文件交给人工复核
+	FilenameDates            bool                  `json:"filename_dates"`           // 元数据中没有时间时使用文件名中的日期时间
+	Sidecars                 bool                  `json:"sidecars"`                 // 元数据和文件名中都没有时间时读取 XMP 和 Google Takeout 旁车文件
+	FolderDates              folderDatesConfig     `json:"folder_dates"`             // 没有其他来源时使用文件夹名中的日期
+	ScreenCaptures           screenCaptureConfig   `json:"screen_captures"`          // 截图和录屏使用单独的前缀（和文件夹）
+	DeviceRules              []deviceRule          `json:"device_rules"`             // 按相机使用别名前缀或单独的文件夹
+	CorrectExtensions        bool                  `json:"correct_extensions"`       // 扩展名与文件内容不符时，重命名时改用正确的扩展名
+	Extensions               extensionPolicy       `json:"extensions"`               // 新文件名中扩展名的大小写和同义词
 }
 
+// 备份模式
+const (
+	backupModeFull      = "full"      // 打包整个目标目录
+	backupModeSelective = "selective" // 只打包本次运行会修改的文件
+	backupModeSnapshot  = "snapshot"  // 用 reflink/硬链接把目录镜像到备份目录，不压缩
+)
+
 func loadConfig() Config {
 	defaultConfig := Config{
 		ImagePrefix:              "IMG",
 		VideoPrefix:              "VID",
+		AudioPrefix:              "AUD",
 		TargetTimezone:           "+08:00",
 		SupportedImageExtensions: []string{"jpg", "jpeg", "png", "heic", "webp", "gif"},
 		SupportedVideoExtensions: []string{"mp4", "mov", "avi", "mkv"},
+		SupportedAudioExtensions: []string{"mp3", "m4a", "wav", "flac", "ogg"},
+		BackupMode:               backupModeFull,
+		FilenameTimezone:         filenameTimezoneTarget,
+		TimestampSanity:          timestampSanityConfig{MinYear: 1990, MaxFutureSkew: "24h"},
+		LowConfidence:            lowConfidenceConfig{Action: reviewActionMove, Folder: "_needs_review"},
+		ScreenCaptures:           screenCaptureConfig{ScreenshotPrefix: "SCR", RecordingPrefix: "REC"},
+		Extensions:               extensionPolicy{Case: extensionCaseKeep},
 	}
 	configFilename := "config.json"
 	absConfigPath, err := filepath.Abs(configFilename)
@@ -49,7 +76,8 @@ func loadConfig() Config {
 		log.Printf("INFO: Config file %s not found, using default settings.", absConfigPath)
 		return defaultConfig
 	}
-	var userConfig Config
+	// 以默认配置为基础解析，配置文件中没有出现的键保持默认值
+	userConfig := defaultConfig
 	if err := json.Unmarshal(configFile, &userConfig); err != nil {
 		log.Printf("WARNING: Could not parse config file %s (%v), using default settings.", absConfigPath, err)
 		return defaultConfig
@@ -83,6 +111,24 @@ func parseTimeZone(tzStr string) (*time.Location, error) {
 }
 
 func main() {
+	// 子命令拥有各自独立的参数，必须在解析全局参数之前分发
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "restore":
+			runRestore(os.Args[2:])
+			return
+		case "backup":
+			runBackupCommand(os.Args[2:])
+			return
+		case "shift":
+			runShift(os.Args[2:])
+			return
+		case "review":
+			runReview(os.Args[2:])
+			return
+		}
+	}
+
 	// 设置和解析命令行参数
 	flag.Usage = ui.ShowHelp
 	showVersion := flag.Bool("version", false, "Display the application version and exit.")
@@ -105,31 +151,42 @@ func main() {
 	cfg := loadConfig()
 	imageExtMap := sliceToMap(cfg.SupportedImageExtensions)
 	videoExtMap := sliceToMap(cfg.SupportedVideoExtensions)
+	audioExtMap := sliceToMap(cfg.SupportedAudioExtensions)
 
 	// REFACTORED: 立即解析时区，确立其权威地位
 	targetLocation, err := parseTimeZone(cfg.TargetTimezone)
 	if err != nil {	log.Fatalf("FATAL: Invalid 'target_timezone' in config.json: '%s'. Error: %v", cfg.TargetTimezone, err) }
 	log.Printf("INFO: Target timezone set to '%s'.", cfg.TargetTimezone)
+	switch cfg.BackupMode {
+	case backupModeFull, backupModeSelective, backupModeSnapshot:
+	default:
+		log.Fatalf("FATAL: Invalid 'backup_mode' in config.json: '%s'. Expected '%s', '%s' or '%s'.", cfg.BackupMode, backupModeFull, backupModeSelective, backupModeSnapshot)
+	}
+	if _, err := parseSize(cfg.Retention.MaxTotalSize); err != nil {
+		log.Fatalf("FATAL: Invalid 'retention.max_total_size' in config.json: %v", err)
+	}
+	if cfg.FilenameTimezone != filenameTimezoneTarget && cfg.FilenameTimezone != filenameTimezoneLocal {
+		log.Fatalf("FATAL: Invalid 'filename_timezone' in config.json: '%s'. Expected '%s' or '%s'.", cfg.FilenameTimezone, filenameTimezoneTarget, filenameTimezoneLocal)
+	}
+	zones, err := newTimeZones(cfg, targetLocation)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	if cfg.TimezoneFromGPS { log.Printf("INFO: Resolving capture timezones from GPS coordinates.") }
+	clockRules, err := parseClockCorrections(cfg.ClockCorrections)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	sanity, err := newTimestampSanity(cfg.TimestampSanity)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	review, err := newReviewPolicy(cfg.LowConfidence)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	if err := checkScreenCaptures(cfg.ScreenCaptures); err != nil { log.Fatalf("FATAL: %v", err) }
+	if err := checkDeviceRules(cfg.DeviceRules); err != nil { log.Fatalf("FATAL: %v", err) }
+	if err := checkExtensionPolicy(cfg.Extensions); err != nil { log.Fatalf("FATAL: %v", err) }
+	warnUnsupportedSynonyms(cfg.Extensions, imageExtMap, videoExtMap, audioExtMap)
+	if review.enabled { log.Printf("INFO: Files with a confidence below '%s' will be %s.", review.threshold, review.describeAction()) }
 	// log.Printf("DEBUG: targetLocation is %#v", targetLocation)	// 调试日志，生产环境应禁用
 	
 	// 检查 exiftool 依赖
-	var exiftoolPath string
-	exiftoolFound := false
-	if *exiftoolOverridePath != "" {
-		if _, err := os.Stat(*exiftoolOverridePath); err == nil {
-			exiftoolPath = *exiftoolOverridePath
-			exiftoolFound = true
-			log.Printf("INFO: Using exiftool from user-provided path: %s", exiftoolPath)
-		} else {
-			log.Fatalf("FATAL: exiftool not found at the path provided by --exiftool-path: %s", *exiftoolOverridePath)
-		}
-	} else {
-		pathInSystem, err := exec.LookPath("exiftool")
-		if err == nil {
-			exiftoolPath = pathInSystem
-			exiftoolFound = true
-		}
-	}
+	exiftoolPath := findExiftool(*exiftoolOverridePath)
+	exiftoolFound := exiftoolPath != ""
 
 	if !exiftoolFound {
 		ui.ShowExiftoolWarning()
@@ -155,8 +212,19 @@ func main() {
 		log.Fatalf("ERROR: Invalid target directory: '%s'. Directory does not exist or is not a directory.", absPath)
 	}
 	
+	// 生成处理计划：读取每个文件的权威时间并解决命名冲突。选择性备份和预检都需要依据这份计划。
+	fmt.Println("\nAnalyzing files...")
+	plan, err := buildPlan(absPath, *maxDepth, exiftoolPath, cfg, imageExtMap, videoExtMap, audioExtMap, zones, clockRules, sanity, review)
+	if err != nil { log.Fatalf("File processing failed during directory traversal: %v", err) }
+	// 在确认之前发现空间和权限问题，避免留下处理了一半的目录
+	preflight := runPreflight(absPath, *backupDir, !*noBackup, cfg.BackupMode, plan)
+	if folder := cfg.LowConfidence.reviewFolder(); folder != "" { preflight.ReviewFolder = filepath.Join(absPath, folder) }
+
 	// 显示执行计划
-	ui.ShowExecutionPlan(absPath, !*noBackup, *backupDir, exiftoolFound, cfg.SupportedImageExtensions, cfg.SupportedVideoExtensions, *maxDepth)
+	ui.ShowExecutionPlan(absPath, !*noBackup, *backupDir, cfg.BackupMode, exiftoolFound, cfg.SupportedImageExtensions, cfg.SupportedVideoExtensions, cfg.SupportedAudioExtensions, *maxDepth, preflight)
+	if len(preflight.Problems) > 0 {
+		log.Fatalf("ERROR: Preflight checks failed. No files were modified.")
+	}
 
 	// 请求用户确认
 	if !*autoConfirm {
@@ -166,105 +234,113 @@ func main() {
 	}
 
 	// 执行备份
-	if !*noBackup {
-		fmt.Println("\n--- Starting Backup ---")
-		if err := createBackup(absPath, *backupDir); err != nil {
-			if *autoConfirm {
-				log.Fatalf("ERROR: Backup failed in automated mode (--yes). Aborting operation.")
-			} else {
-				if !ui.RequestContinueOnFailure(fmt.Sprintf("ERROR: Backup failed! (%v). Continue anyway?", err)) {
-					log.Println("Operation cancelled."); os.Exit(1)
-				}
-			}
-		} else {
-			fmt.Println("Backup completed successfully!")
-		}
-		fmt.Println("-----------------------")
-	}
+	var archivePath string
+	var manifest *backupManifest
+	if !*noBackup { archivePath, manifest = backupBeforeChanges(absPath, *backupDir, cfg, plan) }
 
-	// 开始处理文件 (有微小但关键的修改)
+	// 开始处理文件：先统一执行重命名，再逐个补全元数据和同步时间
 	fmt.Println("\nStarting file processing...")
-	cleanAbsPath := filepath.Clean(absPath)
-
-	err = filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil { log.Printf("Error: Failed to access path '%s': %v\n", path, err); return err }
-		if *maxDepth != -1 {
-			relPath, err := filepath.Rel(cleanAbsPath, path)
-			if err != nil { return err }
-			currentDepth := 0
-			if relPath != "." {
-				currentDepth = strings.Count(relPath, string(filepath.Separator)) + 1
-			}
-			if d.IsDir() && currentDepth > *maxDepth {
-				return filepath.SkipDir
-			}
-		}
-		if d.IsDir() { return nil }
+	if err := createTargetDirs(plan); err != nil { log.Fatalf("ERROR: Could not create the destination folders: %v", err) }
+	renameFiles(plan)
+	saveRenames(archivePath, manifest, plan)
 
-		ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
-		isImage := imageExtMap[ext]
-		isVideo := videoExtMap[ext]
-		if !isImage && !isVideo { return nil }
-
-		var prefix string
-		if isImage { prefix = cfg.ImagePrefix } else { prefix = cfg.VideoPrefix }
-		
-		// CHANGE: 将权威的 targetLocation 对象传递给 processFile
-		processFile(path, prefix, exiftoolPath, cfg, imageExtMap, targetLocation)
-		return nil
-	})
+	for _, pf := range plan {
+		processFile(pf, exiftoolPath, cfg)
+	}
+	saveRenamedChecksums(archivePath, manifest)
 
-	if err != nil { log.Fatalf("File processing failed during directory traversal: %v", err) }
 	fmt.Println("\n========================================")
 	fmt.Println("All files have been processed!")
 }
 
-// CHANGE: 函数签名变更，接收权威的 targetLocation
-func processFile(path, prefix, exiftoolPath string, cfg Config, imageExtMap map[string]bool, targetLocation *time.Location) {
-	fmt.Println("----------------------------------------")
-	fmt.Printf("Processing files: '%s'\n", filepath.Base(path))
+// backupBeforeChanges 在修改任何文件之前创建并校验备份，然后应用保留策略，并标记与快照共享 inode 的文件。
+// 备份失败或无法校验时直接退出，不修改任何文件。
+func backupBeforeChanges(sourceDir, backupDir string, cfg Config, plan []*plannedFile) (string, *backupManifest) {
+	fmt.Println("\n--- Starting Backup ---")
+	archivePath, manifest, err := createBackup(sourceDir, backupDir, cfg.BackupMode, plan)
+	if err == nil {
+		fmt.Println("Verifying backup...")
+		err = verifyArchive(archivePath, manifest)
+		if err == nil { err = verifySourceUnchanged(manifest) }
+	}
+	// 备份无法通过校验时绝不继续修改文件：一个损坏的备份比没有备份更危险。
+	if err != nil {
+		// 未通过校验的归档不能留在原名下，否则会被当作有效备份计入保留策略，挤掉完好的旧备份
+		if archivePath != "" && pathExists(archivePath) {
+			if failedPath, renameErr := markBackupFailed(archivePath); renameErr != nil {
+				log.Printf("WARNING: Could not mark the unverified backup '%s' as failed: %v", archivePath, renameErr)
+			} else {
+				log.Printf("INFO: The unverified backup was kept as '%s' for inspection; it is not counted as a backup.", failedPath)
+			}
+		}
+		log.Fatalf("ERROR: Backup failed or could not be verified (%v). Refusing to modify any files. Use -no-backup to run without a backup.", err)
+	}
+	fmt.Printf("Backup completed and verified (%d entries).\n", len(manifest.Entries))
+	// 只有在新备份通过校验之后才清理旧备份，且新备份本身永远不会被删除
+	if cfg.Retention.enabled() {
+		fmt.Println("Applying retention policy...")
+		removed, freed, err := applyRetention(backupDir, cfg.Retention, archivePath, false)
+		if err != nil {
+			log.Printf("WARNING: Could not apply the retention policy: %v", err)
+		} else if removed > 0 {
+			fmt.Printf("Removed %d old backups, freed %s.\n", removed, formatSize(freed))
+		}
+	}
+	// 与快照共享 inode 的文件在就地修改之前必须先断开硬链接
+	hardlinked := manifest.hardlinkedPaths()
+	for _, pf := range plan { pf.sharesInode = hardlinked[pf.Path] }
+	fmt.Println("-----------------------")
+	return archivePath, manifest
+}
 
-	// CHANGE: 将 targetLocation 传递给 getAuthoritativeTime
-	authoritativeTime, source, isAuthoritative, err := getAuthoritativeTime(path, exiftoolPath, imageExtMap, targetLocation)
-	if err != nil { log.Printf("  └─ ERROR: Failed to determine authoritative time for %s: %v\n", path, err); return }
+// findExiftool 返回 exiftool 的路径：指定了 overridePath 时使用它（不存在则退出），否则在 PATH 中查找。
+// 找不到时返回空字符串。
+func findExiftool(overridePath string) string {
+	if overridePath != "" {
+		if _, err := os.Stat(overridePath); err != nil {
+			log.Fatalf("FATAL: exiftool not found at the path provided by --exiftool-path: %s", overridePath)
+		}
+		log.Printf("INFO: Using exiftool from user-provided path: %s", overridePath)
+		return overridePath
+	}
+	pathInSystem, err := exec.LookPath("exiftool")
+	if err != nil { return "" }
+	return pathInSystem
+}
 
-	// REFACTORED: 这是整个智能方案的核心！将绝对时刻标准化到目标时区。
-	standardizedTime := authoritativeTime.In(targetLocation)
-	
-	// 从现在起，所有操作都使用 standardizedTime
-	// --- OLD ---
-	// 直接使用原始的 isAuthoritative
-	// newBaseName := generateNewFilename(standardizedTime, prefix, path, isAuthoritative)
-	// --- NEW ---
-	// 逻辑修正：幂等性修复，“预支”权威时间身份。
-	// 修正目的：确保单次运行即可将具备有效毫秒 mtime 的文件提升至亚秒级文件名标准
-	// 
-	// 如果当前来源不是 EXIF (即 source != "EXIF")，但存在 exiftoolPath (稍后会补录元数据)，
-	// 我们检查 standardizedTime 的毫秒是否有效。
-	// 如果有效，将从 mtime (回退) 中获得的时间 "提升" 为权威时间。
-	if !isAuthoritative && exiftoolPath != "" {
-		roundedMs := (standardizedTime.Nanosecond() + 500_000) / 1_000_000
-		if roundedMs > 0 { isAuthoritative = true }
-	}
-	// 此时传入的 isAuthoritative 可能是被我们刚刚 “提升” 过的
-	newBaseName := generateNewFilename(standardizedTime, prefix, path, isAuthoritative)
-	// -----------
-	currentBaseName := filepath.Base(path)
-	finalNewPath := path
-
-	if newBaseName != currentBaseName {
-		idealNewPath := filepath.Join(filepath.Dir(path), newBaseName)
-		finalNewPath, err = getUniquePath(idealNewPath)
-		if err != nil { log.Printf("  └─ ERROR: Failed to create unique new path for %s: %v\n", idealNewPath, err); return }
-		if err := os.Rename(path, finalNewPath); err != nil {
-			log.Printf("  └─ ERROR: Failed to  rename the file to '%s': %v\n", filepath.Base(finalNewPath), err); return
+// CHANGE: 函数签名变更，接收已经完成时间解析和重命名的计划
+func processFile(pf *plannedFile, exiftoolPath string, cfg Config) {
+	fmt.Println("----------------------------------------")
+	fmt.Printf("Processing files: '%s'\n", filepath.Base(pf.Path))
+
+	// 从现在起，所有操作都使用计划中的 standardizedTime
+	standardizedTime := pf.Time
+	source := pf.Source
+	finalNewPath := pf.currentPath
+
+	switch {
+	case pf.renameErr != nil:
+		log.Printf("  └─ ERROR: Failed to  rename the file to '%s': %v\n", filepath.Base(pf.TargetPath), pf.renameErr); return
+	case pf.Quarantined && pf.renamed:
+		fmt.Printf("  └─ INFO: Moved to '%s' for review, left unchanged. (Source: %s, confidence: %s)\n", filepath.Dir(finalNewPath), source, pf.Confidence); return
+	case pf.renamed:
+		fmt.Printf("  └─ INFO: Renamed to '%s' (Source: %s, confidence: %s)\n", filepath.Base(finalNewPath), source, pf.Confidence)
+	default:
+		fmt.Printf("  └─ INFO: Filename matches standard. No rename performed. (Source: %s, confidence: %s)\n", source, pf.Confidence)
+	}
+
+	// 硬链接快照的“修改前复制”：先换成独立副本，再修改元数据和 mtime；只改名的文件不需要复制
+	if pf.sharesInode && pf.rewritesInPlace() {
+		if err := breakHardlink(finalNewPath); err != nil {
+			log.Printf("  └─ ERROR: Failed to detach '%s' from the snapshot, skipping it to keep the backup intact: %v\n", filepath.Base(finalNewPath), err); return
 		}
-		fmt.Printf("  └─ INFO: Renamed to '%s' (Source: %s)\n", filepath.Base(finalNewPath), source)
-	} else {
-		fmt.Printf("  └─ INFO: Filename matches standard. No rename performed. (Source: %s)\n", source)
 	}
 
-	if err := enrichMetadata(finalNewPath, standardizedTime, exiftoolPath, cfg, imageExtMap); err != nil {
+	if pf.IsAudio {
+		fmt.Println("  └─ INFO: Skipping metadata enrichment for audio files.")
+	} else if !pf.writesTimeTags() {
+		fmt.Printf("  └─ INFO: Skipping metadata enrichment: the time comes from the %s, not from metadata.\n", pf.Confidence)
+	} else if err := enrichMetadata(finalNewPath, pf.metadataTime(), pf.Meta, pf.Correction, exiftoolPath, cfg, pf.IsImage); err != nil {
 		log.Printf("  └─ ERROR: Failed to enrich metadata: %v\n", err)
 	} else if exiftoolPath != "" {
 		fmt.Println("  └─ INFO: Metadata checked and enriched.")
@@ -277,152 +353,170 @@ func processFile(path, prefix, exiftoolPath string, cfg Config, imageExtMap map[
 	}
 }
 
-// getExifDate 调用 exiftool 来读取一个指定文件的单个元数据标签。
-func getExifDate(filePath, tagName string, exiftoolPath string) (string, error) {
-	args := []string{ "-charset", "UTF8", "-q", "-m", "-p", "$" + tagName, filePath	}
-	cmd := exec.Command(exiftoolPath, args...)
-	
-	// 使用两个独立的 bytes.Buffer 分别捕获 stdout 和 stderr。
-	var stdoutBuf bytes.Buffer
-	var stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
-
-	// log.Printf("DEBUG: Executing exiftool command: %s %s", exiftoolPath, strings.Join(cmd.Args[1:], " "))
-
-	if err := cmd.Run(); err != nil {
-		fullOutput := strings.TrimSpace(stdoutBuf.String()) + "\n" + strings.TrimSpace(stderrBuf.String())
-		return "", fmt.Errorf("exiftool read error for tag '%s' on file '%s': %w, output: %s", tagName, filepath.Base(filePath), err, fullOutput)
-	}
-	
-	dateStr := strings.TrimSpace(stdoutBuf.String())	
-	if dateStr == "" || dateStr == "0000:00:00 00:00:00" { return "", nil }
-	return dateStr, nil
+// authoritativeTime 是 getAuthoritativeTime 的结果。
+type authoritativeTime struct {
+	Time            time.Time
+	Source          string     // 时间的来源，用于输出
+	IsAuthoritative bool       // 是否来自元数据、文件名或旁车文件（可以在文件名中使用毫秒）
+	HasOffset       bool       // 标签本身带有时区偏移，此时 Time 的时区就是拍摄时的偏移
+	GPSChecked      bool       // 已经用 GPS 时间核对（或替换），相机时钟修正规则不再适用
+	Confidence      confidence // 时间的可信程度
 }
 
-
 // REFACTORED: 完全重写的 getAuthoritativeTime 函数，实现了智能解析逻辑。
-func getAuthoritativeTime(path string, exiftoolPath string, imageExtMap map[string]bool, targetLocation *time.Location) (time.Time, string, bool, error) {
-	if exiftoolPath != "" {
-		isImage := imageExtMap[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]
-		
-		var timeTags []string
-		if isImage {
-			// 优先使用带时区的复合标签，其次是 DateTimeOriginal
-			timeTags = []string{"Composite:SubSecDateTimeOriginal", "DateTimeOriginal"}
-		} else {
-			// 视频标签，通常被认为是 UTC
-			timeTags = []string{"MediaCreateDate", "TrackCreateDate", "CreateDate"}
+// 来源依次为：元数据、文件名、旁车文件、文件夹名、mtime，其中文件名、旁车文件和文件夹名需要在配置中启用。pf.Prefix 用于识别本程序之前生成的文件名。
+// naiveLocation 根据不带时区的时间的钟面读数，返回解释它时使用的时区（视频可能是 UTC，否则为拍摄地、行程或目标时区）。
+// sanity 拒绝的候选时间会被跳过，继续尝试下一个来源。
+func getAuthoritativeTime(pf *plannedFile, meta mediaMetadata, exiftoolPath string, naiveLocation func(wall time.Time) *time.Location, sanity *timestampSanity) (authoritativeTime, error) {
+	path := pf.Path
+	switch {
+	case pf.IsAudio:
+		times, err := readAudioTimes(path)
+		if err != nil { fmt.Printf("  └─ WARNING: Could not read the audio tags: %v\n", err) }
+		for _, candidate := range times {
+			hasOffset := hasTimeOffset(candidate.value)
+			parsedTime, err := parseCandidateTime(candidate.value, hasOffset, naiveLocation)
+			if err != nil { continue }
+			if reason := sanity.check(parsedTime); reason != "" {
+				fmt.Printf("  └─ WARNING: Ignoring %s '%s': %s.\n", candidate.tag, candidate.value, reason)
+				continue
+			}
+			// mvhd 是 UTC 时刻，不包含录音地的时区偏移
+			at := authoritativeTime{Time: parsedTime, IsAuthoritative: true, HasOffset: hasOffset && !candidate.utc, Confidence: confidenceNaive}
+			details := []string{candidate.tag}
+			if at.HasOffset {
+				details = append(details, parsedTime.Format("-07:00"))
+				at.Confidence = confidenceOffset
+			}
+			at.Source = "metadata (" + strings.Join(details, ", ") + ")"
+			return at, nil
 		}
+		fmt.Println("  └─ INFO: No recording time found in the audio tags.")
+	case exiftoolPath != "":
+		isImage := pf.IsImage
+		
+		timeTags := videoTimeTags
+		if isImage { timeTags = imageTimeTags }
 
 		for _, tag := range timeTags {
-			dateStr, err := getExifDate(path, tag, exiftoolPath)
-			if err != nil {
-				// 如果 exiftool 报告错误（如文件编码问题），记录但不中断查找其他标签
-				// log.Printf("  └─ DEBUG: ExifTool failed to read tag '%s' for '%s': %v", tag, filepath.Base(path), err)	// 显示调试日志，生产环境可选择禁用
-				continue
-			}
+			dateStr := meta.Get(tag)
 			if dateStr == "" {
 				// dateStr 为空说明标签不存在或无意义
 				continue
 			}
 
-			// 尝试解析时间字符串
-			var parsedTime time.Time
-			var parseErr error
-
 			// 检查是否是带时区的格式
-			if strings.Contains(dateStr, "+") || strings.Contains(dateStr, "-") || strings.HasSuffix(dateStr, "Z") {
-				parsedTime, parseErr = parseExifTime(dateStr, time.UTC) // 初始解析，已包含时区，使用UTC解析，得到绝对时刻
-			} else {
-				// 无时区信息，根据文件类型应用规则
-				var assumedLocation *time.Location
-				if isImage {
-					// 图片的无时区时间，假定为目标时区
-					assumedLocation = targetLocation
-				} else {
-					// 视频的无时区时间，假定为 UTC
-					assumedLocation = time.UTC
-				}
-				parsedTime, parseErr = parseExifTime(dateStr, assumedLocation)
+			hasOffset := hasTimeOffset(dateStr)
+			if !isImage && videoOffsetTimeTags[tag] && !hasOffset { continue }
+			// 图片的时间不带时区时，与对应的 OffsetTime 标签组合
+			label := tag
+			if isImage && !hasOffset {
+				dateStr, label = withImageOffset(tag, dateStr, meta)
+				hasOffset = label != tag
 			}
+			parsedTime, parseErr := parseCandidateTime(dateStr, hasOffset, naiveLocation)
 			
 			if parseErr == nil {
-				return parsedTime, "metadata (" + tag + ")", true, nil
+				at := authoritativeTime{Time: parsedTime, IsAuthoritative: true, HasOffset: hasOffset, Confidence: confidenceNaive}
+				details := []string{label}
+				// 记录拍摄时的时区偏移
+				if hasOffset {
+					details = append(details, parsedTime.Format("-07:00"))
+					at.Confidence = confidenceOffset
+				}
+				// 先与 GPS 时间核对：相机时间本身不合理时，可能由 GPS 时间代替
+				if isImage {
+					var check string
+					if at, check = checkAgainstGPS(at, meta, sanity); check != "" { details = append(details, check) }
+				}
+				if reason := sanity.check(at.Time); reason != "" {
+					fmt.Printf("  └─ WARNING: Ignoring %s '%s': %s.\n", tag, dateStr, reason)
+					continue
+				}
+				at.Source = "metadata (" + strings.Join(details, ", ") + ")"
+				return at, nil
 			}
 			// log.Printf("  └─ DEBUG: Failed to parse metadata time '%s' (tag: %s) for '%s': %v", dateStr, tag, filepath.Base(path), parseErr)	// 调试日志，生产环境应禁用
 		}
 		fmt.Println("  └─ INFO: No relevant metadata found.")
-	} else {
+	default:
 		fmt.Printf("  └─ INFO: ExifTool not found. Cannot read metadata.") // 修正提示语
 	}
 
+	// 文件名中的日期时间（手机、聊天软件和截图工具常这样命名）
+	if wall, dateOnly, ok := filenameTime(path, pf.Prefix); ok && pf.filenameDates {
+		t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), naiveLocation(wall))
+		if reason := sanity.check(t); reason != "" {
+			fmt.Printf("  └─ WARNING: Ignoring the date in the filename: %s.\n", reason)
+		} else {
+			source := "filename"
+			if dateOnly { source = "filename (date only)" }
+			return authoritativeTime{Time: t, Source: source, IsAuthoritative: true, Confidence: confidenceFilename}, nil
+		}
+	}
+
+	// 旁车文件：XMP 或 Google Takeout 的 JSON
+	var sidecars []sidecarTime
+	if pf.sidecars { sidecars = readSidecarTimes(path, exiftoolPath) }
+	for _, sidecar := range sidecars {
+		t, err := parseCandidateTime(sidecar.value, hasTimeOffset(sidecar.value), naiveLocation)
+		if err != nil { continue }
+		if reason := sanity.check(t); reason != "" {
+			fmt.Printf("  └─ WARNING: Ignoring %s '%s': %s.\n", sidecar.source, sidecar.value, reason)
+			continue
+		}
+		return authoritativeTime{Time: t, Source: "sidecar (" + sidecar.source + ")", IsAuthoritative: true, Confidence: confidenceSidecar}, nil
+	}
+
+	// 文件夹名中的日期（如 "2019-07 Trip to Rome"），分散时同一文件夹中的文件依次相隔一秒。只用于命名，不写入元数据（见 writesTimeTags）
+	if wall, precision, folder, ok := folderDate(filepath.Dir(path), pf.dateRoot); ok {
+		wall = wall.Add(time.Duration(pf.folderOrder) * time.Second)
+		t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, naiveLocation(wall))
+		if reason := sanity.check(t); reason != "" {
+			fmt.Printf("  └─ WARNING: Ignoring the date in folder '%s': %s.\n", folder, reason)
+		} else {
+			source := fmt.Sprintf("folder '%s' (%s)", folder, describeFolderDate(wall, precision))
+			return authoritativeTime{Time: t, Source: source, Confidence: confidenceFolder}, nil
+		}
+	}
+
 	// 回退到文件 mtime
 	fmt.Println("  └─ INFO: Falling back to file modification time (mtime).")
 	fileInfo, err := os.Stat(path)
-	if err != nil { return time.Time{}, "", false, fmt.Errorf("failed to stat file '%s' for mtime: %w", filepath.Base(path), err) }
-	return fileInfo.ModTime(), "mtime", false, nil
+	if err != nil { return authoritativeTime{}, fmt.Errorf("failed to stat file '%s' for mtime: %w", filepath.Base(path), err) }
+	// mtime 是最后的来源，即使不合理也只能使用它
+	if reason := sanity.check(fileInfo.ModTime()); reason != "" {
+		fmt.Printf("  └─ WARNING: The modification time is also implausible (%s).\n", reason)
+	}
+	return authoritativeTime{Time: fileInfo.ModTime(), Source: "mtime", Confidence: confidenceMtime}, nil
+}
+
+// hasTimeOffset 报告 exiftool 格式的时间字符串是否带有时区偏移。日期部分以冒号分隔，因此出现 +、- 或结尾的 Z 即为偏移。
+func hasTimeOffset(dateStr string) bool {
+	return strings.Contains(dateStr, "+") || strings.Contains(dateStr, "-") || strings.HasSuffix(dateStr, "Z")
+}
+
+// parseCandidateTime 解析一个候选时间。带时区的时间直接得到绝对时刻；
+// 不带时区的时间先按 UTC 解析得到钟面读数，再由 naiveLocation 决定它属于哪个时区。
+func parseCandidateTime(dateStr string, hasOffset bool, naiveLocation func(wall time.Time) *time.Location) (time.Time, error) {
+	t, err := parseExifTime(dateStr, time.UTC)
+	if err != nil || hasOffset { return t, err }
+	return parseExifTime(dateStr, naiveLocation(t))
 }
 
-// REFACTORED & ENHANCED: 函数签名和逻辑变更，通过单词调用写入更全面的元数据标签。
-func enrichMetadata(path string, t time.Time, exiftoolPath string, cfg Config, imageExtMap map[string]bool) error {
+// REFACTORED & ENHANCED: 函数签名和逻辑变更，通过单次调用写入更全面的元数据标签。
+// 需要写入哪些标签由规划阶段读取到的元数据决定，只填补缺失的标签；有相机时钟修正时改写已有的时间标签。
+func enrichMetadata(path string, t time.Time, meta mediaMetadata, correction string, exiftoolPath string, cfg Config, isImage bool) error {
 	if exiftoolPath == "" {
 		fmt.Println("  └─ INFO: Skipping metadata enrichment ('exiftool' not found).")
 		return nil
 	}
 
-	var args []string = []string{"-charset", "UTF8"}
-	isImage := imageExtMap[strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))]
+	updates := metadataUpdates(t, meta, isImage, correction)
 
-	if isImage {
-		// === 图片处理逻辑，分为三个独立的步骤 ===
-
-		// 无论时间精度如何，文件都应该有精确到秒的时间信息。
-		wallClockStr := t.Format("2006:01:02 15:04:05")
-		args = append(args, "-if", `not $DateTimeOriginal or $DateTimeOriginal eq "0000:00:00 00:00:00"`, fmt.Sprintf("-DateTimeOriginal=%s", wallClockStr))
-		args = append(args, "-if", `not $CreateDate or $CreateDate eq "0000:00:00 00:00:00"`, fmt.Sprintf("-CreateDate=%s", wallClockStr))
-		args = append(args, "-if", `not $ModifyDate or $ModifyDate eq "0000:00:00 00:00:00"`, fmt.Sprintf("-ModifyDate=%s", wallClockStr))
-
-		// 时区为基础时间戳提供上下文，其存在与否与毫秒无关。
-		offsetStr := t.Format("-07:00")
-		args = append(args, "-if", `not $OffsetTimeOriginal`, fmt.Sprintf("-OffsetTimeOriginal=%s", offsetStr))
-		args = append(args, "-if", `not $OffsetTimeDigitized`, fmt.Sprintf("-OffsetTimeDigitized=%s", offsetStr))
-		args = append(args, "-if", `not $OffsetTime`, fmt.Sprintf("-OffsetTime=%s", offsetStr))
-
-		// 只有当四舍五入后的毫秒数大于零时，写入才有意义。
-		roundedMs := (t.Nanosecond() + 500_000) / 1_000_000
-		if roundedMs > 0 {
-			if roundedMs >= 1000 { roundedMs = 999 }
-			subsecStr := fmt.Sprintf("%03d", roundedMs)
-			args = append(args, "-if", `not $SubSecTimeOriginal`, fmt.Sprintf("-SubSecTimeOriginal=%s", subsecStr))
-			args = append(args, "-if", `not $SubSecTimeDigitized`, fmt.Sprintf("-SubSecTimeDigitized=%s", subsecStr))
-			args = append(args, "-if", `not $SubSecTime`, fmt.Sprintf("-SubSecTime=%s", subsecStr))
-		}
-
-	} else {
-		// === 视频处理逻辑 ===
-		// 准备视频所需的 UTC 时间字符串
-		utcTimeStr := t.UTC().Format("2006:01:02 15:04:05")
-
-		// 定义要写入的 QuickTime 标签
-		videoTags := []string{
-			"MediaCreateDate", "TrackCreateDate", "CreateDate",
-			"MediaModifyDate", "TrackModifyDate", "ModifyDate",
-		}
-
-		// 构建单一的参数列表
-		for _, tag := range videoTags {
-			// QuickTime 标签需要明确指定分组
-			fullTagName := fmt.Sprintf("QuickTime:%s", tag)
-			condition := fmt.Sprintf(`not $%s or $%s eq "0000:00:00 00:00:00"`, fullTagName, fullTagName)
-			arg := fmt.Sprintf("-%s=%s", fullTagName, utcTimeStr)
-			args = append(args, "-if", condition, arg)
-		}
-	}
-
-	// 如果除了 -charset 参数之外，没有任何需要执行的操作，则直接返回
-	// 这里需要判断 args 的长度是否只有 -charset 的两个参数，则直接返回
-	if len(args) == 2 { return nil }
+	// 没有任何需要执行的操作，则直接返回
+	if len(updates) == 0 { return nil }
+	args := append([]string{"-charset", "UTF8"}, updates...)
 
 	// 添加通用参数，然后是文件路径
 	args = append(args, "-common_args", "-q", "-m", "-overwrite_original", path)
@@ -449,6 +543,7 @@ func parseExifTime(dateStr string, location *time.Location) (time.Time, error) {
 	layouts := []string{
 		"2006:01:02 15:04:05.999999999-07:00",
 		"2006:01:02 15:04:05-07:00",
+		"2006:01:02 15:04:05.999999999Z07:00",
 		"2006:01:02 15:04:05Z07:00",
 		"2006:01:02 15:04:05.999999999",
 		"2006:01:02 15:04:05",
@@ -462,35 +557,6 @@ func parseExifTime(dateStr string, location *time.Location) (time.Time, error) {
 	return time.Time{}, fmt.Errorf("could not parse date: %s", dateStr)
 }
 
-func createBackup(sourceDir, backupDir string) error {
-	if err := os.MkdirAll(backupDir, 0755); err != nil { return fmt.Errorf("could not create backup directory: %w", err) }
-	backupFilename := fmt.Sprintf("backup_%s_%s.tar.gz", filepath.Base(sourceDir), time.Now().Format("20060102_150405"))
-	backupFilepath := filepath.Join(backupDir, backupFilename)
-	fmt.Printf("Backing up '%s' to '%s'...\n", sourceDir, backupFilepath)
-	file, err := os.Create(backupFilepath); if err != nil { return fmt.Errorf("could not create backup file: %w", err) }; defer file.Close()
-	gw := gzip.NewWriter(file); defer gw.Close()
-	tw := tar.NewWriter(gw); defer tw.Close()
-
-	absBackupDir, err := filepath.Abs(backupDir)
-	if err != nil {
-		return fmt.Errorf("could not resolve absolute path for backup directory: %w", err)
-	}
-	return filepath.Walk(sourceDir, func(path string, info fs.FileInfo, err error) error {
-		if err != nil { return err }
-		if filepath.Clean(path) == filepath.Clean(absBackupDir) {
-			log.Printf("INFO: Skipping backup directory itself: %s", path)
-			return filepath.SkipDir
-		}
-		header, err := tar.FileInfoHeader(info, info.Name()); if err != nil { return err }
-		relPath, err := filepath.Rel(sourceDir, path); if err != nil { return err }
-		header.Name = relPath
-		if err := tw.WriteHeader(header); err != nil { return err }
-		if !info.Mode().IsRegular() { return nil }
-		f, err := os.Open(path); if err != nil { return err }; defer f.Close()
-		_, err = io.Copy(tw, f); return err
-	})
-}
-
 func sliceToMap(s []string) map[string]bool {
 	m := make(map[string]bool); for _, v := range s { m[v] = true }; return m
 }
@@ -499,16 +565,9 @@ func syncFileTimestamp(path string, t time.Time) error {
 	return os.Chtimes(path, t, t) 
 }
 
-func getUniquePath(path string) (string, error) {
-	if _, err := os.Stat(path); os.IsNotExist(err) { return path, nil }
-	dir, ext := filepath.Dir(path), filepath.Ext(path)
-	baseName := strings.TrimSuffix(filepath.Base(path), ext)
-	randNum, err := rand.Int(rand.Reader, big.NewInt(1000)); if err != nil { return "", err }
-	return filepath.Join(dir, fmt.Sprintf("%s_[%03d]%s", baseName, randNum, ext)), nil
-}
-
-func generateNewFilename(t time.Time, prefix, originalPath string, isAuthoritative bool) string {
-	ext, baseTime := filepath.Ext(originalPath), t.Format("20060102_150405")
+// generateNewFilename 生成标准文件名。ext 是带点的扩展名，通常就是原文件的扩展名，更正扩展名时为识别出的内容对应的扩展名。
+func generateNewFilename(t time.Time, prefix, ext string, isAuthoritative bool) string {
+	baseTime := t.Format("20060102_150405")
 	if isAuthoritative {
 		// 增加半毫秒，以实现四舍五入
 		roundedMs := (t.Nanosecond() + 500000) / 1000000
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// manifestVersion 是清单文件格式的版本号，格式发生不兼容的变化时递增。
+const manifestVersion = 1
+
+// backupManifest 记录了一个备份中每个条目的元信息和校验和，
+// 以 <归档名>.manifest.json 的形式保存在归档旁边。
+type backupManifest struct {
+	Version   int             `json:"version"`
+	SourceDir string          `json:"source_dir"`
+	Archive   string          `json:"archive"`
+	Mode      string          `json:"backup_mode"`
+	CreatedAt time.Time       `json:"created_at"`
+	Entries   []manifestEntry `json:"entries"`
+}
+
+// manifestEntry 描述备份中的单个条目。Path 是相对于源目录、以 '/' 分隔的路径。
+type manifestEntry struct {
+	Path    string      `json:"path"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mtime"`
+	SHA256  string      `json:"sha256,omitempty"` // 仅普通文件；reflink 和硬链接快照中的文件没有
+	Method  string      `json:"method,omitempty"` // 快照模式下文件的复制方式
+
+	LinkTarget string `json:"link_target,omitempty"` // 符号链接指向的目标
+	HardlinkTo string `json:"hardlink_to,omitempty"` // 与之共享 inode、内容已保存在该路径下的条目
+
+	// RenamedTo 是文件在处理过程中被重命名后的路径。restore 会把文件还原到 Path，
+	// 并删除 RenamedTo 处改名后的副本，避免同一张照片出现两份。
+	RenamedTo string `json:"renamed_to,omitempty"`
+	// RenamedSHA256 是处理完成后改名副本的 SHA-256。restore 只删除内容仍与之相同的副本，
+	// 其他文件（之后的运行或新照片占用了这个名字，或处理中途退出）会被移到一旁而不是删除。
+	RenamedSHA256 string `json:"renamed_sha256,omitempty"`
+}
+
+// renamedCopy 是处理过程中改名后留下的文件。
+type renamedCopy struct {
+	path   string // 相对于源目录的路径（本地分隔符）
+	sha256 string // 处理完成时的 SHA-256，为空时无法确认该文件是那次运行留下的
+}
+
+func newManifestEntry(path string, info fs.FileInfo) manifestEntry {
+	entry := manifestEntry{Path: path, Mode: info.Mode(), ModTime: info.ModTime()}
+	if info.Mode().IsRegular() { entry.Size = info.Size() }
+	return entry
+}
+
+// manifestPathFor 返回备份对应的清单文件路径。
+func manifestPathFor(backupPath string) string {
+	return backupPath + ".manifest.json"
+}
+
+// writeManifest 先写入临时文件再重命名，保证清单要么完整存在，要么根本不存在。
+func writeManifest(path string, m *backupManifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil { return err }
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil { return err }
+	if err := os.Rename(tmpPath, path); err != nil { os.Remove(tmpPath); return err }
+	return nil
+}
+
+// readManifest 读取备份旁边的清单。旧版本创建的备份没有清单，此时返回 (nil, nil)。
+func readManifest(backupPath string) (*backupManifest, error) {
+	data, err := os.ReadFile(manifestPathFor(backupPath))
+	if os.IsNotExist(err) { return nil, nil }
+	if err != nil { return nil, err }
+	var m backupManifest
+	if err := json.Unmarshal(data, &m); err != nil { return nil, fmt.Errorf("invalid manifest: %w", err) }
+	return &m, nil
+}
+
+// recordRenames 将计划中实际完成的重命名写入清单条目。
+func recordRenames(m *backupManifest, plan []*plannedFile) {
+	index := make(map[string]int, len(m.Entries))
+	for i, entry := range m.Entries { index[entry.Path] = i }
+	for _, pf := range plan {
+		if !pf.renamed { continue }
+		oldRel, err1 := filepath.Rel(m.SourceDir, pf.Path)
+		newRel, err2 := filepath.Rel(m.SourceDir, pf.currentPath)
+		if err1 != nil || err2 != nil { continue }
+		if i, ok := index[filepath.ToSlash(oldRel)]; ok { m.Entries[i].RenamedTo = filepath.ToSlash(newRel) }
+	}
+}
+
+// saveRenames 在重命名完成后把改名记录写入备份清单，restore 据此把文件映射回原来的名字。没有备份时什么也不做。
+func saveRenames(archivePath string, m *backupManifest, plan []*plannedFile) {
+	if m == nil { return }
+	recordRenames(m, plan)
+	if err := writeManifest(manifestPathFor(archivePath), m); err != nil {
+		log.Printf("WARNING: Could not record renamed files in the backup manifest: %v", err)
+	}
+}
+
+// saveRenamedChecksums 在所有文件处理完成后把改名副本的 SHA-256 写入备份清单。没有备份时什么也不做。
+func saveRenamedChecksums(archivePath string, m *backupManifest) {
+	if m == nil { return }
+	recordRenamedChecksums(m)
+	if err := writeManifest(manifestPathFor(archivePath), m); err != nil {
+		log.Printf("WARNING: Could not record the checksums of renamed files in the backup manifest: %v", err)
+	}
+}
+
+// hardlinkedPaths 返回快照中与源文件共享 inode 的文件的绝对路径。
+func (m *backupManifest) hardlinkedPaths() map[string]bool {
+	linked := make(map[string]bool)
+	for _, entry := range m.Entries {
+		if entry.Method == snapshotHardlink { linked[filepath.Join(m.SourceDir, filepath.FromSlash(entry.Path))] = true }
+	}
+	return linked
+}
+
+// recordRenamedChecksums 在所有文件处理完成后记录改名副本的 SHA-256，restore 据此确认副本没有被替换。
+func recordRenamedChecksums(m *backupManifest) {
+	for i, entry := range m.Entries {
+		if entry.RenamedTo == "" { continue }
+		sum, err := fileSHA256(filepath.Join(m.SourceDir, filepath.FromSlash(entry.RenamedTo)))
+		if err == nil { m.Entries[i].RenamedSHA256 = sum }
+	}
+}
+
+// renamedPaths 返回清单中记录的重命名映射：原路径 -> 改名后的副本（路径均为本地分隔符的相对路径）。
+func (m *backupManifest) renamedPaths() map[string]renamedCopy {
+	renamed := make(map[string]renamedCopy)
+	for _, entry := range m.Entries {
+		if entry.RenamedTo == "" { continue }
+		renamed[filepath.Clean(filepath.FromSlash(entry.Path))] = renamedCopy{path: filepath.Clean(filepath.FromSlash(entry.RenamedTo)), sha256: entry.RenamedSHA256}
+	}
+	return renamed
+}
+
+// fileSHA256 计算文件内容的 SHA-256。
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil { return "", err }
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil { return "", err }
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// verifyArchive 重新读取整个归档，逐个比较条目的类型、大小、权限、修改时间和 SHA-256 是否与清单一致，
+// 并确认清单中的每个条目都确实存在于归档之中。
+func verifyArchive(archivePath string, m *backupManifest) error {
+	expected := make(map[string]manifestEntry, len(m.Entries))
+	for _, entry := range m.Entries { expected[filepath.Clean(filepath.FromSlash(entry.Path))] = entry }
+
+	seen := make(map[string]bool, len(m.Entries))
+	_, err := walkArchive(archivePath, func(hdr *tar.Header, relPath string, r io.Reader) error {
+		entry, ok := expected[relPath]
+		if !ok { return fmt.Errorf("'%s' is in the archive but not in the manifest", relPath) }
+		seen[relPath] = true
+
+		info := hdr.FileInfo()
+		if info.Mode().Type() != entry.Mode.Type() { return fmt.Errorf("'%s' has the wrong type in the archive", relPath) }
+		if info.Mode().Perm() != entry.Mode.Perm() { return fmt.Errorf("'%s' has mode %v in the archive, expected %v", relPath, info.Mode().Perm(), entry.Mode.Perm()) }
+		// 符号链接自身的修改时间无法可移植地设置，因此不做比较
+		if info.Mode()&fs.ModeSymlink == 0 && !hdr.ModTime.Round(time.Second).Equal(entry.ModTime.Round(time.Second)) {
+			return fmt.Errorf("'%s' has the wrong modification time in the archive", relPath)
+		}
+		if info.Mode()&fs.ModeSymlink != 0 && hdr.Linkname != entry.LinkTarget {
+			return fmt.Errorf("'%s' points to '%s' in the archive, expected '%s'", relPath, hdr.Linkname, entry.LinkTarget)
+		}
+		if entry.HardlinkTo != "" {
+			if hdr.Typeflag != tar.TypeLink || hdr.Linkname != entry.HardlinkTo {
+				return fmt.Errorf("'%s' is not stored as a hardlink to '%s' in the archive", relPath, entry.HardlinkTo)
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() { return nil }
+		if entry.SHA256 == "" {
+			// reflink 和硬链接在构造上就与源文件内容一致，只需确认大小
+			if hdr.Size != entry.Size { return fmt.Errorf("'%s' is %d bytes in the snapshot, expected %d", relPath, hdr.Size, entry.Size) }
+			return nil
+		}
+
+		hasher := sha256.New()
+		n, err := io.Copy(hasher, r)
+		if err != nil { return fmt.Errorf("reading '%s': %w", relPath, err) }
+		if n != entry.Size { return fmt.Errorf("'%s' is %d bytes in the archive, expected %d", relPath, n, entry.Size) }
+		if sum := hex.EncodeToString(hasher.Sum(nil)); sum != entry.SHA256 {
+			return fmt.Errorf("checksum mismatch for '%s'", relPath)
+		}
+		return nil
+	})
+	if err != nil { return err }
+
+	for relPath := range expected {
+		if !seen[relPath] { return fmt.Errorf("'%s' is listed in the manifest but missing from the archive", relPath) }
+	}
+	return nil
+}
+
+// verifySourceUnchanged 确认源目录中已备份的文件在备份期间没有被修改：
+// 如果文件的大小或修改时间已经与清单不同，备份就不能代表处理前的状态。
+func verifySourceUnchanged(m *backupManifest) error {
+	for _, entry := range m.Entries {
+		if !entry.Mode.IsRegular() { continue }
+		path := filepath.Join(m.SourceDir, filepath.FromSlash(entry.Path))
+		info, err := os.Lstat(path)
+		if err != nil { return fmt.Errorf("'%s' disappeared after the backup: %w", entry.Path, err) }
+		if info.Size() != entry.Size || !info.ModTime().Equal(entry.ModTime) {
+			return fmt.Errorf("'%s' was modified while the backup was running", entry.Path)
+		}
+	}
+	return nil
+}
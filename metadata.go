@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// videoKeysTimeTag 是 iPhone 和许多 Android 手机写入的带时区偏移的视频拍摄时间。
+const videoKeysTimeTag = "Keys:CreationDate"
+
+// 按优先级排列的时间标签。
+var (
+	// 图片：优先使用带时区的复合标签，其次是 DateTimeOriginal
+	imageTimeTags = []string{"Composite:SubSecDateTimeOriginal", "DateTimeOriginal"}
+	// 视频：优先使用带时区偏移的标签（iPhone 和许多 Android 手机写入的 Keys:CreationDate），
+	// 其次是不带时区的 QuickTime 标签，按设备约定视为 UTC 或当地时间
+	videoTimeTags = []string{videoKeysTimeTag, "MediaCreateDate", "TrackCreateDate", "CreateDate"}
+	// 只在带有时区偏移时才采用的视频标签
+	videoOffsetTimeTags = map[string]bool{videoKeysTimeTag: true}
+	// 拍摄设备，用于按相机匹配行程和时钟修正规则
+	deviceTags = []string{"Make", "Model", "SerialNumber", historyTag}
+)
+
+// enrichMetadata 会检查并补全的标签。
+var (
+	imageDateTags    = []string{"DateTimeOriginal", "CreateDate", "ModifyDate"}
+	imageOffsetTags  = []string{"OffsetTimeOriginal", "OffsetTimeDigitized", "OffsetTime"}
+	imageSubSecTags  = []string{"SubSecTimeOriginal", "SubSecTimeDigitized", "SubSecTime"}
+	videoQuickTimeTags = []string{
+		"MediaCreateDate", "TrackCreateDate", "CreateDate",
+		"MediaModifyDate", "TrackModifyDate", "ModifyDate",
+	}
+)
+
+// mediaMetadata 保存一次 exiftool 调用读到的标签值，键为不带分组前缀的标签名。
+type mediaMetadata map[string]string
+
+// Get 返回标签的值。tag 可以带分组前缀（如 "Composite:SubSecDateTimeOriginal"），
+// 全零的占位日期被视为不存在。
+func (m mediaMetadata) Get(tag string) string {
+	if i := strings.LastIndex(tag, ":"); i >= 0 { tag = tag[i+1:] }
+	value := strings.TrimSpace(m[tag])
+	if value == "0000:00:00 00:00:00" { return "" }
+	return value
+}
+
+// metadataTags 返回处理一个文件所需读取的全部标签，时间解析和元数据补全共用这一次读取。
+func metadataTags(isImage bool) []string {
+	if isImage {
+		tags := append([]string{}, imageTimeTags...)
+		tags = append(tags, imageDateTags...)
+		tags = append(tags, imageOffsetTags...)
+		tags = append(tags, imageSubSecTags...)
+		tags = append(tags, deviceTags...)
+		tags = append(tags, imageGPSTimeTag)
+		tags = append(tags, screenCaptureImageTags...)
+		return append(tags, gpsTags...)
+	}
+	tags := append([]string{}, videoTimeTags...)
+	for _, tag := range videoQuickTimeTags { tags = append(tags, "QuickTime:"+tag) }
+	tags = append(tags, deviceTags...)
+	tags = append(tags, screenCaptureVideoTags...)
+	return append(tags, gpsTags...)
+}
+
+// readMetadata 通过单次 exiftool 调用（JSON 输出）读取一个文件的多个标签。
+// exiftool 不可用时返回空的结果。
+func readMetadata(filePath, exiftoolPath string, tags []string) (mediaMetadata, error) {
+	meta := mediaMetadata{}
+	if exiftoolPath == "" { return meta, nil }
+
+	args := []string{"-charset", "UTF8", "-q", "-m", "-j"}
+	for _, tag := range tags { args = append(args, "-"+tag) }
+	args = append(args, filePath)
+	cmd := exec.Command(exiftoolPath, args...)
+
+	// 使用两个独立的 bytes.Buffer 分别捕获 stdout 和 stderr。
+	var stdoutBuf bytes.Buffer
+	var stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	if err := cmd.Run(); err != nil {
+		fullOutput := strings.TrimSpace(stdoutBuf.String()) + "\n" + strings.TrimSpace(stderrBuf.String())
+		return meta, fmt.Errorf("exiftool read error on file '%s': %w, output: %s", filepath.Base(filePath), err, fullOutput)
+	}
+
+	// 没有任何请求的标签时，exiftool 可能不输出任何内容
+	if len(bytes.TrimSpace(stdoutBuf.Bytes())) == 0 { return meta, nil }
+
+	var records []map[string]interface{}
+	decoder := json.NewDecoder(&stdoutBuf)
+	decoder.UseNumber() // 避免把序列号之类的长数字转换成浮点数
+	if err := decoder.Decode(&records); err != nil {
+		return meta, fmt.Errorf("could not parse exiftool output for '%s': %w", filepath.Base(filePath), err)
+	}
+	if len(records) == 0 { return meta, nil }
+	for key, value := range records[0] {
+		if key == "SourceFile" { continue }
+		// 以 '#' 请求数值的标签在输出中可能保留这个后缀
+		meta[strings.TrimSuffix(key, "#")] = fmt.Sprint(value)
+	}
+	return meta, nil
+}
+
+// metadataUpdates 返回为了补全缺失的时间标签而需要传给 exiftool 的写入参数。
+// 已有有效值的标签不会被覆盖；返回空切片表示文件的元数据无需修改。
+// correction 不为空时（相机时钟修正或 shift 命令，如 "clock correction +1h0m0s"），已有的时间标签也会被改写为 t，
+// 并在 XMP 编辑历史中记录这次修改。
+func metadataUpdates(t time.Time, meta mediaMetadata, isImage bool, correction string) []string {
+	args := timeTagUpdates(t, meta, isImage, correction != "")
+	if correction == "" || len(args) == 0 { return args }
+	return append(args,
+		"-XMP-xmpMM:HistoryAction+=edited",
+		"-XMP-xmpMM:HistoryChanged+=/metadata",
+		fmt.Sprintf("-XMP-xmpMM:HistorySoftwareAgent+=%s %s", historyAgent, correction),
+		"-XMP-xmpMM:HistoryWhen+="+time.Now().Format("2006:01:02 15:04:05-07:00"))
+}
+
+// timeTagUpdates 返回时间标签的写入参数。overwrite 为 false 时只填补缺失的标签，否则也改写与 t 不一致的标签。
+func timeTagUpdates(t time.Time, meta mediaMetadata, isImage bool, overwrite bool) []string {
+	var args []string
+	setTag := func(tag, value string) {
+		current := meta.Get(tag)
+		if current == "" || (overwrite && current != value) { args = append(args, fmt.Sprintf("-%s=%s", tag, value)) }
+	}
+
+	if isImage {
+		// === 图片处理逻辑，分为三个独立的步骤 ===
+
+		// 无论时间精度如何，文件都应该有精确到秒的时间信息。
+		wallClockStr := t.Format("2006:01:02 15:04:05")
+		for _, tag := range imageDateTags { setTag(tag, wallClockStr) }
+
+		// 时区为基础时间戳提供上下文，其存在与否与毫秒无关。
+		offsetStr := t.Format("-07:00")
+		for _, tag := range imageOffsetTags { setTag(tag, offsetStr) }
+
+		// 只有当四舍五入后的毫秒数大于零时，写入才有意义。
+		roundedMs := (t.Nanosecond() + 500_000) / 1_000_000
+		if roundedMs > 0 {
+			if roundedMs >= 1000 { roundedMs = 999 }
+			subsecStr := fmt.Sprintf("%03d", roundedMs)
+			for _, tag := range imageSubSecTags { setTag(tag, subsecStr) }
+		}
+		return args
+	}
+
+	// === 视频处理逻辑 ===
+	// QuickTime 标签不带时区，t 已由调用者换算到设备约定的时区（通常是 UTC），并且需要明确指定分组
+	videoTimeStr := t.Format("2006:01:02 15:04:05")
+	for _, tag := range videoQuickTimeTags { setTag("QuickTime:"+tag, videoTimeStr) }
+	// Keys:CreationDate 的优先级最高，改写时间时必须一同改写，否则下次运行又会读到原来的时间。
+	// 它保持原有的时区偏移，缺失时不补写
+	if current := meta.Get(videoKeysTimeTag); overwrite && hasTimeOffset(current) {
+		if original, err := parseExifTime(current, time.UTC); err == nil {
+			setTag(videoKeysTimeTag, t.In(original.Location()).Format("2006:01:02 15:04:05-07:00"))
+		}
+	}
+	return args
+}
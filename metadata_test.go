@@ -0,0 +1,54 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// applyTagUpdates 模拟 exiftool 执行写入参数，返回写入后的元数据。编辑历史等追加写入的标签被忽略。
+func applyTagUpdates(meta mediaMetadata, args []string) mediaMetadata {
+	updated := mediaMetadata{}
+	for tag, value := range meta { updated[tag] = value }
+	for _, arg := range args {
+		tag, value, ok := strings.Cut(strings.TrimPrefix(arg, "-"), "=")
+		if !ok || strings.HasSuffix(tag, "+") { continue }
+		if i := strings.LastIndex(tag, ":"); i >= 0 { tag = tag[i+1:] }
+		updated[tag] = value
+	}
+	return updated
+}
+
+// replanVideo 按规划时的做法从元数据中读取视频时间（不带时区的 QuickTime 标签视为 UTC）。
+func replanVideo(t *testing.T, meta mediaMetadata) time.Time {
+	t.Helper()
+	utc := func(time.Time) *time.Location { return time.UTC }
+	at, err := getAuthoritativeTime(&plannedFile{Path: "VID_test.mp4", Prefix: "VID"}, meta, "exiftool", utc, nil)
+	if err != nil { t.Fatal(err) }
+	if at.Confidence < confidenceNaive { t.Fatalf("time not read from metadata: %s", at.Source) }
+	return at.Time
+}
+
+func TestVideoClockCorrectionRewritesKeys(t *testing.T) {
+	meta := mediaMetadata{
+		"CreationDate":    "2024:07:01 12:00:00+02:00",
+		"MediaCreateDate": "2024:07:01 10:00:00",
+		"TrackCreateDate": "2024:07:01 10:00:00",
+		"CreateDate":      "2024:07:01 10:00:00",
+	}
+	if got, want := replanVideo(t, meta), time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC); !got.Equal(want) { t.Fatalf("before: %v, want %v", got, want) }
+
+	corrected := time.Date(2024, 7, 1, 11, 0, 0, 0, time.UTC)
+	updated := applyTagUpdates(meta, metadataUpdates(corrected, meta, false, historyClockCorrection+" +1h0m0s"))
+	if got := updated.Get(videoKeysTimeTag); got != "2024:07:01 13:00:00+02:00" { t.Errorf("Keys:CreationDate = '%s', want the original +02:00 offset", got) }
+	if got := replanVideo(t, updated); !got.Equal(corrected) { t.Errorf("re-planned time %v, want %v", got, corrected) }
+	// 修正后的元数据不需要再次改写
+	if args := metadataUpdates(corrected, updated, false, historyClockCorrection+" +1h0m0s"); len(args) != 0 { t.Errorf("second run writes %v", args) }
+}
+
+func TestVideoFillDoesNotAddKeys(t *testing.T) {
+	meta := mediaMetadata{"MediaCreateDate": "2024:07:01 10:00:00"}
+	for _, arg := range metadataUpdates(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC), meta, false, "") {
+		if strings.Contains(arg, videoKeysTimeTag) { t.Errorf("filling missing tags writes %s", arg) }
+	}
+}
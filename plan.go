@@ -0,0 +1,459 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// plannedFile 描述了单个媒体文件在本次运行中的处理计划。
+// 先为所有文件生成计划，再统一解决命名冲突，才能保证冲突后缀的分配是确定的。
+type plannedFile struct {
+	Path            string         // 文件在本次运行开始时的路径
+	Prefix          string         // 文件名前缀 (IMG / VID / AUD / SCR / REC)
+	Time            time.Time      // 已标准化到文件名所用时区的权威时间
+	Source          string         // 权威时间的来源，用于输出
+	IsAuthoritative bool           // 是否可以在文件名中使用毫秒
+	IdealPath       string         // 不考虑冲突时的理想路径
+	TargetPath      string         // 解决冲突后的最终路径
+	Meta            mediaMetadata  // 规划时读取到的元数据
+	ModTime         time.Time      // 规划时文件系统中的修改时间
+	MetadataChanges int            // 需要补全的元数据标签数量
+	Correction      string         // 需要写入元数据编辑历史的修改说明（如 "clock correction +1h0m0s"），为空时只补全缺失的标签
+	TagLocation     *time.Location // 写回时间标签时使用的时区：视频为设备约定的 UTC 或当地时区，图片为元数据时间原有的时区偏移；音频为 nil
+	Confidence      confidence     // 权威时间的可信程度
+	Quarantined     bool           // 可信度过低，保留原名移入复核目录，不修改元数据和 mtime
+	IsImage         bool           // 图片文件（否则为视频或音频）
+	IsAudio         bool           // 音频文件：时间由内置读取器读取，不补全元数据
+	ContentType     *fileType      // 根据文件签名识别出的类型；无法识别时为 nil
+	ScreenCapture   bool           // 截图或录屏：使用单独的前缀，配置了文件夹时移入该文件夹
+
+	extension     string // 新文件名使用的扩展名（带点）
+	currentPath   string // 文件此刻实际所在的路径（重命名过程中会变化）
+	renamed       bool   // 重命名是否已经完成
+	renameErr     error  // 重命名失败的原因
+	sharesInode   bool   // 是否与硬链接快照共享 inode
+	filenameDates bool   // 元数据中没有时间时使用文件名中的日期时间
+	sidecars      bool   // 元数据和文件名中都没有时间时读取旁车文件
+	dateRoot      string // 在这一目录及其下各级文件夹的名字中查找日期；为空时不使用文件夹日期
+	folderOrder   int    // 在所在文件夹的媒体文件中按文件名排列的序号，用于分散文件夹日期；不分散时为 0
+	keptExt       bool   // 需要更正扩展名，但与内容相符的扩展名都不受支持，因此保留原扩展名
+}
+
+// collectMediaFiles 遍历目标目录，按遍历顺序返回所有受支持的媒体文件及其前缀。
+func collectMediaFiles(rootDir string, maxDepth int, cfg Config, imageExtMap, videoExtMap, audioExtMap map[string]bool) ([]*plannedFile, error) {
+	var files []*plannedFile
+	cleanRoot := filepath.Clean(rootDir)
+	folderCounts := make(map[string]int)
+
+	err := filepath.WalkDir(rootDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil { log.Printf("Error: Failed to access path '%s': %v\n", path, err); return err }
+		if maxDepth != -1 {
+			relPath, err := filepath.Rel(cleanRoot, path)
+			if err != nil { return err }
+			currentDepth := 0
+			if relPath != "." {
+				currentDepth = strings.Count(relPath, string(filepath.Separator)) + 1
+			}
+			if d.IsDir() && currentDepth > maxDepth {
+				return filepath.SkipDir
+			}
+		}
+		if d.IsDir() {
+			// 复核目录中的文件等待人工确认时间，不参与常规处理
+			if folder := cfg.LowConfidence.reviewFolder(); folder != "" && path == filepath.Join(cleanRoot, folder) { return filepath.SkipDir }
+			return nil
+		}
+
+		kind, content, ok := classifyFile(path, imageExtMap, videoExtMap, audioExtMap)
+		if !ok { return nil }
+		isImage, isAudio := kind == kindImage, kind == kindAudio
+
+		var prefix string
+		switch kind {
+		case kindImage:
+			prefix = cfg.ImagePrefix
+		case kindVideo:
+			prefix = cfg.VideoPrefix
+		default:
+			prefix = cfg.AudioPrefix
+		}
+		// 之前的运行已经识别为截图或录屏的文件保留其前缀，文件名中的时间也不会被当作独立的来源
+		if captured := cfg.ScreenCaptures.prefixFor(isImage); cfg.ScreenCaptures.Enabled && !isAudio && ownFilenamePrefix(path) == captured { prefix = captured }
+		pf := &plannedFile{Path: path, Prefix: prefix, IsImage: isImage, IsAudio: isAudio, ContentType: content, extension: filepath.Ext(path), currentPath: path}
+		// 只改用受支持的扩展名，否则改名后的文件在之后的运行中不会再被处理
+		if cfg.CorrectExtensions && pf.extensionMismatch() {
+			if ext, ok := content.supportedExtension(imageExtMap, videoExtMap, audioExtMap); ok { pf.extension = "." + ext } else { pf.keptExt = true }
+		}
+		pf.extension = cfg.Extensions.normalize(pf.extension)
+		pf.filenameDates, pf.sidecars = cfg.FilenameDates, cfg.Sidecars
+		if cfg.FolderDates.Enabled { pf.dateRoot = cleanRoot }
+		// WalkDir 按文件名顺序访问同一文件夹中的文件
+		if cfg.FolderDates.Spread {
+			pf.folderOrder = folderCounts[filepath.Dir(path)]
+			folderCounts[filepath.Dir(path)]++
+		}
+		files = append(files, pf)
+		return nil
+	})
+	return files, err
+}
+
+// buildPlan 遍历目标目录，为每个受支持的媒体文件生成处理计划，并解决命名冲突。
+func buildPlan(rootDir string, maxDepth int, exiftoolPath string, cfg Config, imageExtMap, videoExtMap, audioExtMap map[string]bool, zones *timeZones, clockRules []clockRule, sanity *timestampSanity, review reviewPolicy) ([]*plannedFile, error) {
+	candidates, err := collectMediaFiles(rootDir, maxDepth, cfg, imageExtMap, videoExtMap, audioExtMap)
+	if err != nil { return nil, err }
+
+	var plan []*plannedFile
+	for _, pf := range candidates {
+		if !planFile(pf, exiftoolPath, zones, clockRules, cfg.DeviceRules, sanity) { continue }
+		// 截图和录屏不是相机拍摄的，它们的规则优先于设备规则
+		if !pf.IsAudio { applyDeviceRule(pf, rootDir, cfg.DeviceRules) }
+		if cfg.ScreenCaptures.Enabled && !pf.IsAudio { applyScreenCapture(pf, rootDir, cfg.ScreenCaptures, exiftoolPath != "") }
+		if review.needsReview(pf.Confidence) {
+			if review.action == reviewActionSkip { fmt.Printf("  └─ INFO: Confidence below '%s', leaving '%s' untouched.\n", review.threshold, filepath.Base(pf.Path)); continue }
+			// 保留原名和原有的元数据、mtime，只移入复核目录
+			pf.Quarantined = true
+			pf.IdealPath = review.quarantinePath(rootDir, pf.Path)
+			pf.MetadataChanges = 0
+			fmt.Printf("  └─ INFO: Confidence below '%s', '%s' will be moved to '%s' for review.\n", review.threshold, filepath.Base(pf.Path), review.folder)
+		}
+		plan = append(plan, pf)
+	}
+	resolveCollisions(plan)
+	return plan, nil
+}
+
+// planFile 读取文件的权威时间，并计算其理想的新文件名。
+// 返回 false 表示该文件无法确定时间，不应进入后续处理。
+func planFile(pf *plannedFile, exiftoolPath string, zones *timeZones, clockRules []clockRule, deviceRules []deviceRule, sanity *timestampSanity) bool {
+	fmt.Println("----------------------------------------")
+	fmt.Printf("Analyzing: '%s'\n", filepath.Base(pf.Path))
+	if pf.extensionMismatch() {
+		fix := "set 'correct_extensions' to fix it"
+		if pf.extensionCorrected() {
+			fix = "it will be renamed to '" + pf.extension + "'"
+		} else if pf.keptExt {
+			fix = "'." + pf.ContentType.ext + "' is not a supported extension, so it is kept"
+		}
+		fmt.Printf("  └─ WARNING: The content is %s, but the extension is '%s' (%s).\n", pf.ContentType.name, filepath.Ext(pf.Path), fix)
+	}
+
+	info, err := os.Stat(pf.Path)
+	if err != nil { log.Printf("  └─ ERROR: Failed to stat %s: %v\n", pf.Path, err); return false }
+	pf.ModTime = info.ModTime()
+
+	isImage := pf.IsImage
+	// 音频文件的时间由内置读取器读取，不调用 exiftool
+	metaTool := exiftoolPath
+	if pf.IsAudio { metaTool = "" }
+	meta, err := readMetadata(pf.Path, metaTool, metadataTags(isImage))
+	if err != nil {
+		// 如果 exiftool 报告错误（如文件编码问题），记录后按没有元数据处理
+		log.Printf("  └─ WARNING: %v\n", err)
+	}
+	pf.Meta = meta
+	resolveDeviceAlias(pf, deviceRules)
+
+	// 拍摄地时区用于解释不带时区的时间：GPS 坐标优先，其次是行程，都没有时使用目标时区。
+	// 内嵌的粗略边界在边境附近可能出错，因此只作为后备：匹配的行程优先于它
+	captureLocation, captureZone, known := zones.captureZone(meta)
+	coarse := known && zones.coarseMap
+	videoUTC := !isImage && !pf.IsAudio && zones.videoRules.isUTC(meta)
+	naiveLocation := func(wall time.Time) *time.Location {
+		if videoUTC { return time.UTC }
+		if known && !coarse { return captureLocation }
+		if loc, _, ok := zones.itineraryZone(meta, wall, true); ok { return loc }
+		if known { return captureLocation }
+		return zones.target
+	}
+
+	at, err := getAuthoritativeTime(pf, meta, exiftoolPath, naiveLocation, sanity)
+	if err != nil { log.Printf("  └─ ERROR: Failed to determine authoritative time for %s: %v\n", pf.Path, err); return false }
+	source, isAuthoritative := at.Source, at.IsAuthoritative
+	pf.Confidence = at.Confidence
+	fmt.Printf("  └─ INFO: Confidence: %s\n", at.Confidence)
+
+	// 相机时钟修正只作用于元数据中的时间，匹配的是相机记录的钟面读数本身
+	if at.Confidence >= confidenceNaive && !at.GPSChecked {
+		rule, found, alreadyCorrected := findClockRule(clockRules, meta, wallClock(at.Time))
+		switch {
+		case alreadyCorrected:
+			fmt.Println("  └─ INFO: Clock correction already recorded in metadata, not applying it again.")
+		case found:
+			at.Time = at.Time.Add(rule.offset)
+			source += " corrected by " + describeOffset(rule.offset)
+			if rule.correctMetadata { pf.Correction = historyClockCorrection + " " + describeOffset(rule.offset) }
+			fmt.Printf("  └─ INFO: Camera clock corrected by %s.\n", describeOffset(rule.offset))
+		}
+	}
+	if !known || coarse {
+		if loc, zone, ok := zones.itineraryZone(meta, at.Time, false); ok { captureLocation, captureZone, known = loc, zone, true }
+	}
+	if known { fmt.Printf("  └─ INFO: Capture timezone: %s\n", captureZone) }
+	switch {
+	case pf.IsAudio:
+	case isImage:
+		// 改写图片的时间标签时保留相机原来的时区偏移，只改变钟面时间
+		if at.Confidence >= confidenceNaive { pf.TagLocation = at.Time.Location() }
+	case videoUTC:
+		pf.TagLocation = time.UTC
+	case known:
+		pf.TagLocation = captureLocation
+	default:
+		pf.TagLocation = zones.target
+	}
+
+	// 这是整个智能方案的核心！将绝对时刻标准化到目标时区（或配置为当地时间时，拍摄地的时区）。
+	standardizedTime := at.Time.In(zones.filenameLocation(at, captureLocation))
+
+	// 不是来自元数据的时间带有毫秒时，文件名同样保留毫秒，这样第一次运行就得到最终的名字，之后的运行不会再改名。
+	if !isAuthoritative && exiftoolPath != "" {
+		roundedMs := (standardizedTime.Nanosecond() + 500_000) / 1_000_000
+		if roundedMs > 0 { isAuthoritative = true }
+	}
+
+	pf.Time = standardizedTime
+	pf.Source = source
+	pf.IsAuthoritative = isAuthoritative
+	newBaseName := generateNewFilename(standardizedTime, pf.Prefix, pf.extension, isAuthoritative)
+	pf.IdealPath = filepath.Join(filepath.Dir(pf.Path), newBaseName)
+	if exiftoolPath != "" && !pf.IsAudio && pf.writesTimeTags() { pf.MetadataChanges = len(metadataUpdates(pf.metadataTime(), meta, isImage, pf.Correction)) }
+	return true
+}
+
+// extensionMismatch 报告文件的扩展名是否与识别出的内容不符。
+func (pf *plannedFile) extensionMismatch() bool {
+	return pf.ContentType != nil && !pf.ContentType.matchesExtension(strings.TrimPrefix(filepath.Ext(pf.Path), "."))
+}
+
+// extensionCorrected 报告新文件名是否改用了与内容相符的扩展名。
+func (pf *plannedFile) extensionCorrected() bool {
+	return pf.extensionMismatch() && pf.ContentType.matchesExtension(strings.TrimPrefix(pf.extension, "."))
+}
+
+// metadataTime 返回写回元数据时使用的时间：视频按其约定换算到 UTC 或当地时区，图片换算到元数据原有的时区偏移（并写入时区偏移标签），
+// 元数据中没有时间的图片保持文件名所用的时区。
+func (pf *plannedFile) metadataTime() time.Time {
+	if pf.TagLocation != nil { return pf.Time.In(pf.TagLocation) }
+	return pf.Time
+}
+
+// writesTimeTags 报告是否把时间写入文件的时间标签。只写入来自元数据的时间和人工指定的时间（后者记录在编辑历史中）：
+// 由文件名、旁车文件、文件夹或 mtime 推断的时间一旦写成 DateTimeOriginal，下次运行就会被当作元数据时间，可信度凭空提高。
+func (pf *plannedFile) writesTimeTags() bool {
+	return pf.Confidence >= confidenceNaive || pf.Correction != ""
+}
+
+// willChange 报告执行计划是否会修改该文件的名称、元数据或修改时间。
+// 只有在 resolveCollisions 之后调用才有意义。
+func (pf *plannedFile) willChange() bool {
+	if pf.Quarantined { return pf.TargetPath != pf.Path }
+	return pf.TargetPath != pf.Path || pf.MetadataChanges > 0 || !pf.ModTime.Equal(pf.Time)
+}
+
+// rewritesInPlace 报告处理时是否会修改文件本身（元数据或 mtime），而不只是改名或移动。
+func (pf *plannedFile) rewritesInPlace() bool {
+	return !pf.Quarantined && (pf.MetadataChanges > 0 || !pf.ModTime.Equal(pf.Time))
+}
+
+// isInsideFolder 报告配置中的文件夹名是否指向目标目录之内。
+func isInsideFolder(folder string) bool {
+	return !filepath.IsAbs(folder) && !strings.HasPrefix(filepath.Clean(folder), "..") && filepath.Clean(folder) != "."
+}
+
+// destinationDir 返回目标目录下的文件夹 folder 中与 dir 对应的位置，保留其相对于目标目录的子目录结构；
+// 已经在该文件夹中的文件不再移动，因此重复运行的结果不变。
+func destinationDir(rootDir, folder, dir string) string {
+	rel, err := filepath.Rel(rootDir, dir)
+	if err != nil || strings.HasPrefix(rel, "..") { return dir }
+	folder = filepath.Clean(folder)
+	if rel == folder || strings.HasPrefix(rel, folder+string(filepath.Separator)) { return dir }
+	return filepath.Join(rootDir, folder, rel)
+}
+
+// createTargetDirs 为所有要移入其他文件夹（复核目录、截图文件夹、设备文件夹）的文件创建目标目录。
+func createTargetDirs(plan []*plannedFile) error {
+	for _, pf := range plan {
+		if filepath.Dir(pf.TargetPath) == filepath.Dir(pf.Path) { continue }
+		if err := os.MkdirAll(filepath.Dir(pf.TargetPath), 0755); err != nil { return err }
+	}
+	return nil
+}
+
+// pathKey 返回判断两个路径是否冲突时使用的键。冲突不区分大小写：只差大小写的名字在 macOS、Windows 等文件系统上是同一个文件，
+// 在区分大小写的文件系统上也不应出现只差扩展名大小写的两个文件（IMG_..._01.jpg 与 IMG_..._01.JPG）。
+func pathKey(path string) string {
+	return strings.ToLower(path)
+}
+
+// sameFile 报告两个路径是否指向同一个文件，例如不区分大小写的文件系统上只差大小写的两个名字。
+func sameFile(a, b string) bool {
+	ai, err := os.Lstat(a)
+	if err != nil { return false }
+	bi, err := os.Lstat(b)
+	if err != nil { return false }
+	return os.SameFile(ai, bi)
+}
+
+// resolveCollisions 为理想路径相同（不区分大小写）的文件分配确定的、有序的冲突后缀 (_01, _02 ...)。
+// 同一组内的文件先按亚秒级时间、再按原文件名排序，因此重复运行总能得到相同的结果。
+// 被计划外文件占用的候选路径会被跳过；计划内文件当前占用的路径在执行时会被腾出，因此不视为冲突。
+func resolveCollisions(plan []*plannedFile) {
+	occupiedByPlan := make(map[string]*plannedFile, len(plan))
+	for _, pf := range plan { occupiedByPlan[pathKey(pf.Path)] = pf }
+	// 候选路径存在时，只有它就是某个计划内文件（名字相同，或在不区分大小写的文件系统上只差大小写）才会被腾出
+	vacatedByPlan := func(candidate string) bool {
+		pf := occupiedByPlan[pathKey(candidate)]
+		return pf != nil && (pf.Path == candidate || sameFile(pf.Path, candidate))
+	}
+
+	groups := make(map[string][]*plannedFile)
+	var order []string
+	for _, pf := range plan {
+		key := pathKey(pf.IdealPath)
+		if _, ok := groups[key]; !ok { order = append(order, key) }
+		groups[key] = append(groups[key], pf)
+	}
+
+	claimed := make(map[string]bool, len(plan))
+	for _, key := range order {
+		members := groups[key]
+		sort.SliceStable(members, func(i, j int) bool {
+			if !members[i].Time.Equal(members[j].Time) { return members[i].Time.Before(members[j].Time) }
+			return filepath.Base(members[i].Path) < filepath.Base(members[j].Path)
+		})
+
+		seq := 0
+		for _, pf := range members {
+			for ; ; seq++ {
+				candidate := collisionCandidate(pf.IdealPath, seq)
+				if claimed[pathKey(candidate)] { continue }
+				if pathExists(candidate) && !vacatedByPlan(candidate) { continue }
+				claimed[pathKey(candidate)] = true
+				pf.TargetPath = candidate
+				seq++
+				break
+			}
+		}
+	}
+}
+
+// collisionCandidate 返回理想路径的第 seq 个候选：0 即理想路径本身，之后依次为 _01, _02 ...
+func collisionCandidate(idealPath string, seq int) string {
+	if seq == 0 { return idealPath }
+	ext := filepath.Ext(idealPath)
+	return fmt.Sprintf("%s_%02d%s", strings.TrimSuffix(idealPath, ext), seq, ext)
+}
+
+// maxRenameAttempts 限制了单个文件因目标被抢占而改选候选路径的次数。
+const maxRenameAttempts = 100
+
+// renameFiles 按依赖顺序执行计划中的所有重命名，确保不会覆盖任何文件。
+// 目标路径仍被另一个待移动的文件占用时，该重命名会被推迟；若出现循环（如两个文件互换名字），
+// 则先将其中一个文件移到临时名称上以打破循环。目标被计划外的文件占用时，沿用冲突策略改选下一个候选。
+func renameFiles(plan []*plannedFile) {
+	claimed := make(map[string]bool, len(plan))
+	var pending []*plannedFile
+	for _, pf := range plan {
+		claimed[pathKey(pf.TargetPath)] = true
+		if pf.TargetPath != pf.Path { pending = append(pending, pf) }
+	}
+
+	for len(pending) > 0 {
+		var deferred []*plannedFile
+		for _, pf := range pending {
+			if pf.TargetPath == pf.currentPath { continue }
+			// 只差大小写的改名（不区分大小写的文件系统上目标就是文件自身）不需要等待
+			if pathExists(pf.TargetPath) && !sameFile(pf.currentPath, pf.TargetPath) { deferred = append(deferred, pf); continue }
+			if err := renameWithRetry(pf, claimed); err != nil {
+				pf.renameErr = err
+				restoreOriginalPath(pf)
+			}
+		}
+		if len(deferred) < len(pending) { pending = deferred; continue }
+
+		// 本轮没有任何进展：剩下的文件要么处于循环中，要么被计划外的文件挡住。
+		// 后一种情况直接改选下一个空闲的候选路径，下一轮即可继续推进。
+		blockedByPlan := make(map[string]bool, len(deferred))
+		for _, pf := range deferred { blockedByPlan[pathKey(pf.currentPath)] = true }
+		reassigned := false
+		for _, pf := range deferred {
+			if blockedByPlan[pathKey(pf.TargetPath)] { continue }
+			pf.TargetPath = nextFreeCandidate(pf, claimed)
+			reassigned = true
+		}
+		pending = deferred
+		if reassigned { continue }
+
+		// 剩下的文件全部处于循环之中。
+		cycleBreaker := pending[0]
+		tmpPath := cycleBreaker.currentPath + ".media-sorter-tmp"
+		if err := renameNoReplace(cycleBreaker.currentPath, tmpPath); err != nil {
+			cycleBreaker.renameErr = fmt.Errorf("could not move file aside to break a naming cycle: %w", err)
+			pending = pending[1:]
+			continue
+		}
+		cycleBreaker.currentPath = tmpPath
+	}
+}
+
+// renameWithRetry 以不覆盖的方式执行重命名。如果目标在规划之后被其他进程抢先创建，
+// 则沿用冲突策略改选下一个空闲的候选路径并重试。
+func renameWithRetry(pf *plannedFile, claimed map[string]bool) error {
+	for attempt := 0; attempt < maxRenameAttempts; attempt++ {
+		if pf.TargetPath == pf.currentPath { return nil }
+		err := renameNoReplace(pf.currentPath, pf.TargetPath)
+		if errors.Is(err, fs.ErrExist) && sameFile(pf.currentPath, pf.TargetPath) { err = renameCaseOnly(pf.currentPath, pf.TargetPath) }
+		if err == nil {
+			pf.currentPath = pf.TargetPath
+			pf.renamed = true
+			return nil
+		}
+		if !errors.Is(err, fs.ErrExist) { return err }
+		log.Printf("  └─ WARNING: '%s' was created by another process, choosing the next free name.\n", filepath.Base(pf.TargetPath))
+		pf.TargetPath = nextFreeCandidate(pf, claimed)
+	}
+	return fmt.Errorf("gave up after %d attempts to find a free name for '%s'", maxRenameAttempts, filepath.Base(pf.IdealPath))
+}
+
+// nextFreeCandidate 按照与 resolveCollisions 相同的后缀序列，返回下一个未被占用也未被认领的路径。
+// 如果序列恰好轮到文件当前所在的路径，则直接留在原地。
+func nextFreeCandidate(pf *plannedFile, claimed map[string]bool) string {
+	for seq := 1; ; seq++ {
+		candidate := collisionCandidate(pf.IdealPath, seq)
+		if candidate == pf.currentPath { return candidate }
+		if claimed[pathKey(candidate)] || pathExists(candidate) { continue }
+		claimed[pathKey(candidate)] = true
+		return candidate
+	}
+}
+
+// renameCaseOnly 在不区分大小写的文件系统上执行只差大小写的改名：目标“已存在”（就是文件自身），
+// 不覆盖的重命名会失败，因此先移到临时名称上，再移到目标名称。
+func renameCaseOnly(oldPath, newPath string) error {
+	tmpPath := oldPath + ".media-sorter-tmp"
+	if err := renameNoReplace(oldPath, tmpPath); err != nil { return err }
+	if err := renameNoReplace(tmpPath, newPath); err != nil {
+		renameNoReplace(tmpPath, oldPath)
+		return err
+	}
+	return nil
+}
+
+// restoreOriginalPath 在重命名最终失败时，尽量把曾被移到临时名称上的文件放回原处。
+func restoreOriginalPath(pf *plannedFile) {
+	if pf.currentPath == pf.Path { return }
+	if err := renameNoReplace(pf.currentPath, pf.Path); err == nil { pf.currentPath = pf.Path }
+}
+
+func pathExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil || !os.IsNotExist(err)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveCollisions(t *testing.T) {
+	base := time.Date(2024, 7, 1, 12, 0, 0, 0, time.UTC)
+	type file struct {
+		name  string        // 当前文件名
+		ideal string        // 理想文件名
+		after time.Duration // 与 base 相差的时间
+	}
+	tests := []struct {
+		name   string
+		files  []file
+		others []string // 计划外的已有文件
+		want   []string // 每个文件分配到的文件名
+	}{
+		{"same second, ordered by subsecond time",
+			[]file{{"c.jpg", "IMG_20240701_120000.jpg", 900 * time.Millisecond}, {"a.jpg", "IMG_20240701_120000.jpg", 100 * time.Millisecond}, {"b.jpg", "IMG_20240701_120000.jpg", 500 * time.Millisecond}},
+			nil,
+			[]string{"IMG_20240701_120000_02.jpg", "IMG_20240701_120000.jpg", "IMG_20240701_120000_01.jpg"}},
+		{"same time, ordered by name",
+			[]file{{"b.jpg", "IMG_20240701_120000.jpg", 0}, {"a.jpg", "IMG_20240701_120000.jpg", 0}},
+			nil,
+			[]string{"IMG_20240701_120000_01.jpg", "IMG_20240701_120000.jpg"}},
+		{"extension case",
+			[]file{{"a.JPG", "IMG_20240701_120000.JPG", 0}, {"b.jpg", "IMG_20240701_120000.jpg", 0}},
+			nil,
+			[]string{"IMG_20240701_120000.JPG", "IMG_20240701_120000_01.jpg"}},
+		{"occupied by an unplanned file",
+			[]file{{"a.jpg", "IMG_20240701_120000.jpg", 0}},
+			[]string{"IMG_20240701_120000.jpg"},
+			[]string{"IMG_20240701_120000_01.jpg"}},
+		{"already renamed",
+			[]file{{"IMG_20240701_120000_01.jpg", "IMG_20240701_120000.jpg", 0}, {"IMG_20240701_120000.jpg", "IMG_20240701_120000.jpg", 0}, {"IMG_20240701_120000_02.jpg", "IMG_20240701_120000.jpg", 0}},
+			nil,
+			[]string{"IMG_20240701_120000_01.jpg", "IMG_20240701_120000.jpg", "IMG_20240701_120000_02.jpg"}},
+		{"already renamed, with a new file",
+			[]file{{"IMG_20240701_120000.jpg", "IMG_20240701_120000.jpg", 0}, {"IMG_20240701_120000_01.jpg", "IMG_20240701_120000.jpg", time.Second / 2}, {"new.jpg", "IMG_20240701_120000.jpg", time.Second / 4}},
+			nil,
+			[]string{"IMG_20240701_120000.jpg", "IMG_20240701_120000_02.jpg", "IMG_20240701_120000_01.jpg"}},
+	}
+	for _, tt := range tests {
+		dir := t.TempDir()
+		var plan []*plannedFile
+		for _, f := range tt.files {
+			path := filepath.Join(dir, f.name)
+			if err := os.WriteFile(path, nil, 0o644); err != nil { t.Fatal(err) }
+			plan = append(plan, &plannedFile{Path: path, IdealPath: filepath.Join(dir, f.ideal), Time: base.Add(f.after)})
+		}
+		for _, name := range tt.others {
+			if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil { t.Fatal(err) }
+		}
+		resolveCollisions(plan)
+		for i, pf := range plan {
+			if got := filepath.Base(pf.TargetPath); got != tt.want[i] {
+				t.Errorf("%s: '%s' -> '%s', want '%s'", tt.name, tt.files[i].name, got, tt.want[i])
+			}
+		}
+		// 再次运行：文件已经在分配到的路径上，结果不变
+		for _, pf := range plan {
+			if err := os.Rename(pf.Path, pf.Path+".tmp"); err != nil { t.Fatal(err) }
+		}
+		for _, pf := range plan {
+			if err := os.Rename(pf.Path+".tmp", pf.TargetPath); err != nil { t.Fatal(err) }
+			pf.Path, pf.TargetPath = pf.TargetPath, ""
+		}
+		resolveCollisions(plan)
+		for _, pf := range plan {
+			if pf.TargetPath != pf.Path { t.Errorf("%s: second run moves '%s' to '%s'", tt.name, filepath.Base(pf.Path), filepath.Base(pf.TargetPath)) }
+		}
+	}
+}
+
+func TestCollectSkipsReviewFolder(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.jpg", filepath.Join("_needs_review", "b.jpg")} {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { t.Fatal(err) }
+		if err := os.WriteFile(path, nil, 0o644); err != nil { t.Fatal(err) }
+	}
+	imageExtMap := sliceToMap([]string{"jpg"})
+	tests := []struct {
+		name string
+		cfg  lowConfidenceConfig
+		want int
+	}{
+		{"moving to the review folder", lowConfidenceConfig{Threshold: "filename", Action: reviewActionMove, Folder: "_needs_review"}, 1},
+		{"disabled", lowConfidenceConfig{Action: reviewActionMove, Folder: "_needs_review"}, 2},
+		{"skipping in place", lowConfidenceConfig{Threshold: "filename", Action: reviewActionSkip, Folder: "_needs_review"}, 2},
+	}
+	for _, tt := range tests {
+		files, err := collectMediaFiles(dir, -1, Config{LowConfidence: tt.cfg}, imageExtMap, nil, nil)
+		if err != nil { t.Fatal(err) }
+		if len(files) != tt.want { t.Errorf("%s: collected %d files, want %d", tt.name, len(files), tt.want) }
+	}
+}
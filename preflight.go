@@ -0,0 +1,209 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"media-sorter/ui"
+)
+
+// backupSpaceMargin 是备份空间估算之外额外要求的余量（估算值的 1/20），
+// 用于覆盖 tar 头、清单以及估算期间新增的文件。
+const backupSpaceMargin = 20
+
+// runPreflight 在确认之前检查本次运行能否完整地执行：备份所需的空间、备份目录和目标目录是否可写，
+// 以及计划中每个会被修改的文件和目录的写权限。Problems 中的任何一项都会导致运行中途失败。
+func runPreflight(sourceDir, backupDir string, backupEnabled bool, mode string, plan []*plannedFile) ui.PreflightReport {
+	report := ui.PreflightReport{MediaFiles: len(plan)}
+	var changing []*plannedFile
+	for _, pf := range plan {
+		if pf.willChange() { changing = append(changing, pf) }
+		if pf.Quarantined { report.ReviewFiles++ }
+		if pf.ScreenCapture { report.ScreenCaptures++ }
+		if pf.extensionMismatch() {
+			report.ExtensionMismatches++
+			if pf.extensionCorrected() && !pf.Quarantined { report.ExtensionsCorrected++ }
+		}
+	}
+	report.ChangingFiles = len(changing)
+	if len(changing) > 0 { checkFilesystem(sourceDir, "Target directory", &report) }
+
+	if backupEnabled && len(changing) > 0 {
+		// 备份目录可能尚未创建，检查它最近的已存在的上级目录
+		existing := nearestExistingDir(backupDir)
+		free := checkFilesystem(existing, "Backup directory", &report)
+		estimate, err := estimateBackupSize(sourceDir, backupDir, existing, mode, plan)
+		if err != nil {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("Could not estimate the backup size: %v", err))
+		} else {
+			report.BackupSize = formatSize(estimate)
+			if free >= 0 {
+				report.BackupFree = formatSize(free)
+				if needed := estimate + estimate/backupSpaceMargin; needed > free {
+					report.Problems = append(report.Problems, fmt.Sprintf("Not enough free space for the backup: about %s needed, %s available.", formatSize(needed), formatSize(free)))
+				}
+			}
+		}
+	}
+
+	checkPlanWritable(changing, &report)
+	sharesInodes := backupEnabled && mode == backupModeSnapshot && len(changing) > 0 && snapshotSharesInodes(sourceDir, nearestExistingDir(backupDir))
+	checkRewriteSpace(sourceDir, sharesInodes, changing, &report)
+	return report
+}
+
+// checkFilesystem 检查目录是否可写、是否位于只读挂载上，返回可用空间（未知时为 -1）。
+func checkFilesystem(dir, label string, report *ui.PreflightReport) int64 {
+	free, readOnly, err := diskStatus(dir)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		free = -1
+	case err != nil:
+		report.Warnings = append(report.Warnings, fmt.Sprintf("Could not determine free space for '%s': %v", dir, err))
+		free = -1
+	case readOnly:
+		report.Problems = append(report.Problems, fmt.Sprintf("%s '%s' is on a read-only filesystem.", label, dir))
+		return free
+	}
+	if err := checkWritable(dir); err != nil {
+		report.Problems = append(report.Problems, fmt.Sprintf("%s '%s' is not writable: %v", label, dir, describeWriteError(err)))
+	}
+	return free
+}
+
+// checkPlanWritable 检查每个会被修改的文件：重命名需要所在目录的写权限，
+// 补全元数据和同步 mtime 需要文件本身的写权限（exiftool 会在同一目录下写出新文件再替换）。
+// 移入其他目录（设备文件夹、截图文件夹、复核文件夹）的文件还需要目标目录的写权限；
+// 目标目录尚不存在时，检查它最近的已存在的上级目录能否创建它。
+func checkPlanWritable(changing []*plannedFile, report *ui.PreflightReport) {
+	checkedDirs := make(map[string]bool)
+	for _, pf := range changing {
+		dir := filepath.Dir(pf.Path)
+		needsDir := pf.TargetPath != pf.Path || pf.MetadataChanges > 0
+		if needsDir && !checkedDirs[dir] {
+			checkedDirs[dir] = true
+			if err := checkWritable(dir); err != nil {
+				report.Problems = append(report.Problems, fmt.Sprintf("Directory '%s' is not writable, files in it cannot be renamed or rewritten: %v", dir, describeWriteError(err)))
+			}
+		}
+		if destDir := filepath.Dir(pf.TargetPath); destDir != dir && !checkedDirs[destDir] {
+			checkedDirs[destDir] = true
+			existing := nearestExistingDir(destDir)
+			if err := checkWritable(existing); err != nil {
+				report.Problems = append(report.Problems, fmt.Sprintf("Destination directory '%s' is not writable, files cannot be moved into it: %v", existing, describeWriteError(err)))
+			}
+		}
+		if pf.MetadataChanges > 0 || !pf.ModTime.Equal(pf.Time) {
+			if err := checkWritable(pf.Path); err != nil {
+				report.Problems = append(report.Problems, fmt.Sprintf("File '%s' is not writable: %v", pf.Path, describeWriteError(err)))
+			}
+		}
+	}
+}
+
+// checkRewriteSpace 检查目标文件系统能否容纳处理过程中的临时副本：exiftool 会先写出完整的新文件再替换原文件，
+// 快照与源文件共享 inode 时（sharesInodes），每个被就地修改的文件在修改前还要复制一份独立的副本。
+// 只改名或移入复核文件夹的文件不需要副本。
+func checkRewriteSpace(sourceDir string, sharesInodes bool, changing []*plannedFile, report *ui.PreflightReport) {
+	free, _, err := diskStatus(sourceDir)
+	if err != nil { return }
+	var largest, copies int64
+	var largestPath string
+	for _, pf := range changing {
+		info, err := os.Stat(pf.Path)
+		if err != nil { continue }
+		if !pf.rewritesInPlace() { continue }
+		if pf.MetadataChanges > 0 && info.Size() > largest { largest, largestPath = info.Size(), pf.Path }
+		if sharesInodes { copies += info.Size() }
+	}
+	if needed := largest + copies; needed > free {
+		if largestPath == "" { largestPath = sourceDir }
+		report.Problems = append(report.Problems, fmt.Sprintf("Not enough free space in the target directory to rewrite files (about %s needed for '%s', %s available).", formatSize(needed), largestPath, formatSize(free)))
+	}
+}
+
+// estimateBackupSize 估算备份需要占用的空间。媒体文件几乎无法再压缩，因此按原始大小计算。
+// 快照与源目录位于同一文件系统时使用 reflink 或硬链接，几乎不占空间。
+func estimateBackupSize(sourceDir, backupDir, existingBackupDir, mode string, plan []*plannedFile) (int64, error) {
+	if mode == backupModeSelective {
+		var total int64
+		for _, pf := range plan {
+			if !pf.willChange() { continue }
+			info, err := os.Lstat(pf.Path)
+			if err != nil { return 0, err }
+			total += info.Size()
+		}
+		return total, nil
+	}
+	if mode == backupModeSnapshot && sameFilesystem(sourceDir, existingBackupDir) { return 0, nil }
+
+	absBackupDir, err := filepath.Abs(backupDir)
+	if err != nil { return 0, err }
+	var total int64
+	err = filepath.WalkDir(sourceDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil { return err }
+		if d.IsDir() && filepath.Clean(path) == filepath.Clean(absBackupDir) { return filepath.SkipDir }
+		if !d.Type().IsRegular() { return nil }
+		info, err := d.Info()
+		if err != nil { return err }
+		total += info.Size()
+		return nil
+	})
+	return total, err
+}
+
+// snapshotSharesInodes 报告快照是否会用硬链接与源文件共享 inode：备份目录与源目录位于同一文件系统、
+// 且文件系统不支持 reflink 时才会如此（支持 reflink 时使用写时复制克隆，不同文件系统上则是普通复制）。
+func snapshotSharesInodes(sourceDir, existingBackupDir string) bool {
+	return sameFilesystem(sourceDir, existingBackupDir) && !reflinkSupported(existingBackupDir)
+}
+
+// reflinkSupported 在 dir 中创建一个临时文件并尝试克隆它，以判断文件系统是否支持 reflink。无法判断时返回 false。
+func reflinkSupported(dir string) bool {
+	f, err := os.CreateTemp(dir, ".media-sorter-probe-*")
+	if err != nil { return false }
+	defer os.Remove(f.Name())
+	_, err = f.WriteString("reflink probe")
+	if closeErr := f.Close(); err != nil || closeErr != nil { return false }
+	clone := f.Name() + ".clone"
+	if err := reflinkFile(f.Name(), clone); err != nil { return false }
+	os.Remove(clone)
+	return true
+}
+
+// sameFilesystem 判断两个路径是否位于同一个设备上。无法判断时返回 false，按最坏情况估算。
+func sameFilesystem(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	if errA != nil || errB != nil { return false }
+	idA, _, okA := fileIdentityOf(infoA)
+	idB, _, okB := fileIdentityOf(infoB)
+	return okA && okB && idA.dev == idB.dev
+}
+
+// nearestExistingDir 返回 path 自身或其最近的已存在的上级目录。
+func nearestExistingDir(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil { return path }
+	for {
+		if info, err := os.Stat(abs); err == nil && info.IsDir() { return abs }
+		parent := filepath.Dir(abs)
+		if parent == abs { return abs }
+		abs = parent
+	}
+}
+
+// describeWriteError 把常见的写权限错误转换为简短的说明。
+func describeWriteError(err error) string {
+	switch {
+	case errors.Is(err, syscall.EROFS):
+		return "read-only filesystem"
+	case errors.Is(err, fs.ErrPermission):
+		return "permission denied"
+	}
+	return err.Error()
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// ficlone 即 Linux 的 FICLONE ioctl：让目标文件与源文件共享数据块（写时复制）。
+const ficlone = 0x40049409
+
+var errReflinkUnsupported = errors.New("reflink not supported")
+
+// reflinkFile 创建 dest 并将其克隆为 src 的写时复制副本。失败时不会留下 dest。
+func reflinkFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil { return err }
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil { return err }
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, out.Fd(), ficlone, in.Fd())
+	closeErr := out.Close()
+	if errno != 0 {
+		os.Remove(dest)
+		return errno
+	}
+	if closeErr != nil { os.Remove(dest) }
+	return closeErr
+}
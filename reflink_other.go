@@ -0,0 +1,12 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+var errReflinkUnsupported = errors.New("reflink not supported")
+
+// reflinkFile 在非 Linux 平台上不可用，快照会回退到硬链接或普通复制。
+func reflinkFile(src, dest string) error {
+	return errReflinkUnsupported
+}
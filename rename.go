@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// linkAndUnlink 是不支持原子“不覆盖重命名”时的回退方案：
+// 先创建硬链接（目标已存在时 link 会失败），成功后再删除旧路径。
+// 在连硬链接都不支持的文件系统（如 FAT/exFAT）上，只能退回到“先检查再重命名”。
+func linkAndUnlink(oldPath, newPath string) error {
+	err := os.Link(oldPath, newPath)
+	if err == nil {
+		if err := os.Remove(oldPath); err != nil {
+			// 删除旧路径失败时撤销硬链接，避免同一文件出现两个名字
+			os.Remove(newPath)
+			return err
+		}
+		return nil
+	}
+	if errors.Is(err, fs.ErrExist) { return err }
+
+	if _, statErr := os.Lstat(newPath); statErr == nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: fs.ErrExist}
+	}
+	return os.Rename(oldPath, newPath)
+}
@@ -0,0 +1,42 @@
+//go:build linux && (amd64 || arm64)
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// renameNoReplaceFlag 即 Linux 的 RENAME_NOREPLACE：目标已存在时 renameat2 返回 EEXIST。
+const renameNoReplaceFlag = 0x1
+
+// renameNoReplace 原子地重命名文件，目标已存在时返回满足 errors.Is(err, fs.ErrExist) 的错误。
+// 文件系统或内核不支持 RENAME_NOREPLACE 时，回退到硬链接加删除。
+func renameNoReplace(oldPath, newPath string) error {
+	err := renameat2(oldPath, newPath, renameNoReplaceFlag)
+	switch err {
+	case nil:
+		return nil
+	case syscall.EINVAL, syscall.ENOSYS, syscall.ENOTSUP:
+		return linkAndUnlink(oldPath, newPath)
+	}
+	return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: err}
+}
+
+func renameat2(oldPath, newPath string, flags uintptr) error {
+	oldPtr, err := syscall.BytePtrFromString(oldPath)
+	if err != nil { return err }
+	newPtr, err := syscall.BytePtrFromString(newPath)
+	if err != nil { return err }
+	dirfd := atFDCWD
+	_, _, errno := syscall.Syscall6(sysRenameat2,
+		uintptr(dirfd), uintptr(unsafe.Pointer(oldPtr)),
+		uintptr(dirfd), uintptr(unsafe.Pointer(newPtr)),
+		flags, 0)
+	if errno != 0 { return errno }
+	return nil
+}
+
+// atFDCWD 即 AT_FDCWD：相对路径以当前工作目录为基准。
+const atFDCWD = -0x64
@@ -0,0 +1,4 @@
+package main
+
+// sysRenameat2 是 renameat2 在 linux/amd64 上的系统调用号（标准库 syscall 包未导出）。
+const sysRenameat2 = 316
@@ -0,0 +1,8 @@
+//go:build !linux || !(amd64 || arm64)
+
+package main
+
+// renameNoReplace 在没有 renameat2 的平台上使用硬链接加删除来实现不覆盖的重命名。
+func renameNoReplace(oldPath, newPath string) error {
+	return linkAndUnlink(oldPath, newPath)
+}
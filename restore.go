@@ -0,0 +1,410 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"media-sorter/ui"
+)
+
+// stringList 是一个可重复指定的命令行标志，例如 -only a.jpg -only sub/。
+type stringList []string
+
+func (s *stringList) String() string     { return strings.Join(*s, ",") }
+func (s *stringList) Set(v string) error { *s = append(*s, v); return nil }
+
+// runRestore 实现 `media-sorter restore [options] [archive] [dir]` 子命令。
+func runRestore(args []string) {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.Usage = ui.ShowHelp
+	backupDir := flags.String("backup-dir", "./media_backups", "Directory where backups are stored.")
+	dryRun := flags.Bool("dry-run", false, "Only list what would be restored or overwritten.")
+	autoConfirm := flags.Bool("yes", false, "Bypass the confirmation prompt.")
+	var only stringList
+	flags.Var(&only, "only", "Restore only this path (relative to the target directory). Can be repeated.")
+	flags.Parse(args)
+
+	// 没有指定归档时，列出备份目录中所有可用的备份
+	if flags.NArg() == 0 {
+		showBackupList(*backupDir, retentionPolicy{})
+		return
+	}
+
+	archivePath := flags.Arg(0)
+	if _, err := os.Stat(archivePath); os.IsNotExist(err) {
+		archivePath = filepath.Join(*backupDir, flags.Arg(0))
+	}
+
+	fmt.Printf("Validating archive '%s'...\n", archivePath)
+	info, err := validateArchive(archivePath)
+	if err != nil { log.Fatalf("ERROR: Archive '%s' is not a valid backup: %v", archivePath, err) }
+	manifest, err := readManifest(archivePath)
+	if err != nil { log.Fatalf("ERROR: Could not read the manifest of '%s': %v", archivePath, err) }
+	if manifest != nil {
+		if err := verifyArchive(archivePath, manifest); err != nil {
+			log.Fatalf("ERROR: Archive '%s' does not match its manifest: %v", archivePath, err)
+		}
+		fmt.Println("Archive matches its manifest (SHA-256 checked).")
+		if info.SourceDir == "" { info.SourceDir = manifest.SourceDir }
+	}
+	fmt.Printf("Archive OK: %d entries, %s of file data.\n", len(info.Entries), formatSize(info.TotalSize))
+
+	targetDir := info.SourceDir
+	if flags.NArg() > 1 { targetDir = flags.Arg(1) }
+	if targetDir == "" {
+		log.Fatalf("ERROR: The archive does not record its source directory. Please specify the target directory.")
+	}
+	absTarget, err := filepath.Abs(targetDir)
+	if err != nil { log.Fatalf("ERROR: Failed to resolve absolute path for target directory '%s': %v", targetDir, err) }
+
+	selected, err := restoreSelector(absTarget, only)
+	if err != nil { log.Fatalf("ERROR: %v", err) }
+
+	// 清单记录了处理过程中被改名的文件：还原原文件后，需要删除改名后的副本
+	var renamed map[string]renamedCopy
+	if manifest != nil { renamed = manifest.renamedPaths() }
+
+	fmt.Printf("\nRestore target: %s\n", absTarget)
+	counts := showRestorePlan(info, absTarget, selected, renamed)
+	if counts == 0 {
+		fmt.Println("Nothing to restore: no archive entries match the selection.")
+		return
+	}
+	if *dryRun {
+		fmt.Println("\nDry run: no files were changed.")
+		return
+	}
+
+	if !*autoConfirm {
+		if !ui.RequestConfirmation() { log.Println("Operation cancelled by user."); os.Exit(0) }
+	}
+
+	restored, err := restoreArchive(archivePath, absTarget, selected, renamed)
+	if err != nil { log.Fatalf("ERROR: Restore failed after %d entries: %v", restored, err) }
+	fmt.Printf("\nRestore completed: %d entries restored into '%s'.\n", restored, absTarget)
+}
+
+// showBackupList 打印备份目录中的所有备份归档。配置了保留策略时，同时标出会被 prune 删除的备份。
+func showBackupList(backupDir string, policy retentionPolicy) {
+	archives, err := listBackups(backupDir)
+	if err != nil { log.Fatalf("ERROR: Could not read backup directory '%s': %v", backupDir, err) }
+	if len(archives) == 0 {
+		fmt.Printf("No backups found in '%s'.\n", backupDir)
+		return
+	}
+	pruned := make(map[string]bool)
+	if policy.enabled() {
+		decisions, err := planPrune(archives, policy, "")
+		if err != nil { log.Fatalf("ERROR: Invalid retention policy: %v", err) }
+		for _, d := range decisions { pruned[d.Archive.Path] = !d.Keep }
+	}
+
+	fmt.Printf("Backups in '%s':\n\n", backupDir)
+	fmt.Printf("  %-50s  %-20s  %10s  %-19s\n", "NAME", "SOURCE", "SIZE", "CREATED")
+	var total int64
+	for _, a := range archives {
+		line := fmt.Sprintf("  %-50s  %-20s  %10s  %s", a.Name, a.SourceName, formatSize(a.Size), a.CreatedAt.Format("2006-01-02 15:04:05"))
+		if a.IsSnapshot { line += "  (snapshot)" }
+		if pruned[a.Path] { line += "  [prune]" }
+		fmt.Println(line)
+		total += a.Size
+	}
+	fmt.Printf("\n%d backups, %s in total.\n", len(archives), formatSize(total))
+	if len(pruned) > 0 {
+		count := 0
+		for _, p := range pruned {
+			if p { count++ }
+		}
+		if count > 0 { fmt.Printf("%d backups fall outside the retention policy; run 'media-sorter backup prune' to remove them.\n", count) }
+	}
+	fmt.Println("\nRun 'media-sorter restore <archive> [dir]' to restore one of them.")
+}
+
+// archiveInfo 汇总了一次校验扫描得到的归档信息。
+type archiveInfo struct {
+	SourceDir string        // 归档中记录的源目录（旧的归档没有此信息）
+	Entries   []*tar.Header // 所有条目，Name 已经过安全检查
+	TotalSize int64         // 普通文件内容的总大小
+}
+
+// walkArchive 按顺序读取备份归档中的每个条目。条目名称在交给 fn 之前会经过安全检查，
+// 并被转换为使用本地路径分隔符的相对路径。
+// archivePath 也可以是一个快照目录。
+func walkArchive(archivePath string, fn func(hdr *tar.Header, relPath string, r io.Reader) error) (sourceDir string, err error) {
+	if info, err := os.Stat(archivePath); err == nil && info.IsDir() {
+		return "", walkSnapshot(archivePath, fn)
+	}
+	file, err := os.Open(archivePath)
+	if err != nil { return "", err }
+	defer file.Close()
+	gr, err := gzip.NewReader(file)
+	if err != nil { return "", fmt.Errorf("not a gzip archive: %w", err) }
+	defer gr.Close()
+
+	tr := tar.NewReader(gr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF { return sourceDir, nil }
+		if err != nil { return sourceDir, err }
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			if dir, ok := hdr.PAXRecords[paxSourceDirKey]; ok { sourceDir = dir }
+			continue
+		}
+		relPath, err := sanitizeArchivePath(hdr.Name)
+		if err != nil { return sourceDir, err }
+		if err := fn(hdr, relPath, tr); err != nil { return sourceDir, err }
+	}
+}
+
+// sanitizeArchivePath 拒绝绝对路径以及试图跳出目标目录的条目。
+func sanitizeArchivePath(name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) || filepath.VolumeName(clean) != "" {
+		return "", fmt.Errorf("unsafe path in archive: %q", name)
+	}
+	return clean, nil
+}
+
+// validateArchive 完整地读取一遍归档（包括所有文件内容），以确认 gzip 校验和与 tar 结构完好。
+func validateArchive(archivePath string) (*archiveInfo, error) {
+	info := &archiveInfo{}
+	sourceDir, err := walkArchive(archivePath, func(hdr *tar.Header, relPath string, r io.Reader) error {
+		hdr.Name = relPath
+		info.Entries = append(info.Entries, hdr)
+		if hdr.Typeflag == tar.TypeReg {
+			n, err := io.Copy(io.Discard, r)
+			if err != nil { return fmt.Errorf("reading '%s': %w", relPath, err) }
+			info.TotalSize += n
+		}
+		return nil
+	})
+	if err != nil { return nil, err }
+	info.SourceDir = sourceDir
+	return info, nil
+}
+
+// restoreSelector 根据 -only 参数构造条目筛选函数；未指定时还原全部内容。
+func restoreSelector(targetDir string, only []string) (func(relPath string) bool, error) {
+	if len(only) == 0 { return func(string) bool { return true }, nil }
+
+	var prefixes []string
+	for _, p := range only {
+		if filepath.IsAbs(p) {
+			rel, err := filepath.Rel(targetDir, p)
+			if err != nil { return nil, fmt.Errorf("cannot restore '%s': %w", p, err) }
+			p = rel
+		}
+		clean, err := sanitizeArchivePath(filepath.ToSlash(p))
+		if err != nil { return nil, fmt.Errorf("cannot restore '%s': path is outside the target directory", p) }
+		prefixes = append(prefixes, clean)
+	}
+	return func(relPath string) bool {
+		for _, prefix := range prefixes {
+			if prefix == "." || relPath == prefix || strings.HasPrefix(relPath, prefix+string(filepath.Separator)) { return true }
+		}
+		return false
+	}, nil
+}
+
+// showRestorePlan 打印每个被选中的条目将会产生的效果，返回被选中的条目数量。
+func showRestorePlan(info *archiveInfo, targetDir string, selected func(string) bool, renamed map[string]renamedCopy) int {
+	count := 0
+	overwrites := 0
+	removals := 0
+	moves := 0
+	for _, hdr := range info.Entries {
+		if !selected(hdr.Name) { continue }
+		count++
+		action := "create"
+		dest := filepath.Join(targetDir, hdr.Name)
+		if current, err := os.Lstat(dest); err == nil {
+			switch {
+			case hdr.Typeflag == tar.TypeDir && current.IsDir():
+				action = "exists"
+			case hdr.Typeflag == tar.TypeReg && current.Mode().IsRegular() &&
+				current.Size() == hdr.Size && current.ModTime().Round(time.Second).Equal(hdr.ModTime.Round(time.Second)):
+				action = "unchanged"
+			default:
+				action = "overwrite"
+				overwrites++
+			}
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir, tar.TypeReg, tar.TypeSymlink, tar.TypeLink:
+		default:
+			action = "skip"
+		}
+		fmt.Printf("  [%-9s] %s\n", action, hdr.Name)
+		if c, ok := renamed[hdr.Name]; ok && c.path != hdr.Name && pathExists(filepath.Join(targetDir, c.path)) {
+			if c.matches(targetDir) {
+				fmt.Printf("  [%-9s] %s (renamed copy of %s)\n", "remove", c.path, hdr.Name)
+				removals++
+			} else {
+				fmt.Printf("  [%-9s] %s (not the renamed copy of %s, it will be kept under another name)\n", "move", c.path, hdr.Name)
+				moves++
+			}
+		}
+	}
+	fmt.Printf("\n%d entries selected, %d existing files would be overwritten, %d renamed copies would be removed, %d other files would be moved aside.\n", count, overwrites, removals, moves)
+	return count
+}
+
+// restoreArchive 将归档中被选中的条目还原到目标目录，保留 tar 头中记录的属主、扩展属性、权限和修改时间，
+// 并重建符号链接和硬链接。原文件还原成功后，清单中记录的改名副本会被删除。
+// 目录的属性在所有文件写完之后再设置，否则会被写入文件的操作改掉。
+func restoreArchive(archivePath, targetDir string, selected func(string) bool, renamed map[string]renamedCopy) (int, error) {
+	restored := 0
+	var dirs []*tar.Header
+	restoredFiles := make(map[string]bool)
+	_, err := walkArchive(archivePath, func(hdr *tar.Header, relPath string, r io.Reader) error {
+		if !selected(relPath) { return nil }
+		dest := filepath.Join(targetDir, relPath)
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil { return err }
+			h := *hdr
+			h.Name = dest
+			dirs = append(dirs, &h)
+		case tar.TypeReg:
+			if err := restoreRegularFile(dest, hdr, r); err != nil { return fmt.Errorf("restoring '%s': %w", relPath, err) }
+			if err := removeRenamedCopy(targetDir, relPath, renamed[relPath], restoredFiles); err != nil { return err }
+			restoredFiles[relPath] = true
+		case tar.TypeSymlink:
+			err := replaceEntry(dest, func(tmpPath string) error { return os.Symlink(hdr.Linkname, tmpPath) })
+			if err != nil { return fmt.Errorf("restoring symlink '%s': %w", relPath, err) }
+			chownIfPermitted(dest, hdr.Uid, hdr.Gid)
+		case tar.TypeLink:
+			// 硬链接只有在它指向的文件也在本次还原中写出时才能重建，否则会链接到一个可能已被修改的文件
+			linkRel, err := sanitizeArchivePath(hdr.Linkname)
+			if err != nil { return err }
+			if !restoredFiles[linkRel] {
+				log.Printf("WARNING: Skipping hardlink '%s': restore it together with '%s'.", relPath, linkRel)
+				return nil
+			}
+			err = replaceEntry(dest, func(tmpPath string) error { return os.Link(filepath.Join(targetDir, linkRel), tmpPath) })
+			if err != nil { return fmt.Errorf("restoring hardlink '%s': %w", relPath, err) }
+			if err := removeRenamedCopy(targetDir, relPath, renamed[relPath], restoredFiles); err != nil { return err }
+		default:
+			log.Printf("WARNING: Skipping unsupported archive entry '%s' (type %q).", relPath, hdr.Typeflag)
+			return nil
+		}
+		restored++
+		return nil
+	})
+	if err != nil { return restored, err }
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := applyHeaderAttrs(dirs[i].Name, dirs[i]); err != nil { return restored, err }
+	}
+	return restored, nil
+}
+
+// applyHeaderAttrs 把 tar 头中记录的属主、扩展属性、权限和时间应用到 path 上。
+// 属主必须最先设置，因为 chown 会清除 setuid/setgid 位。
+func applyHeaderAttrs(path string, hdr *tar.Header) error {
+	chownIfPermitted(path, hdr.Uid, hdr.Gid)
+	if err := writeXattrs(path, headerXattrs(hdr)); err != nil {
+		log.Printf("WARNING: Could not restore extended attributes of '%s': %v", path, err)
+	}
+	if err := os.Chmod(path, hdr.FileInfo().Mode().Perm()); err != nil { return err }
+	return os.Chtimes(path, accessTimeOf(hdr), hdr.ModTime)
+}
+
+// chownIfPermitted 尝试还原属主。只有 root 才能把文件交给其他用户，因此权限不足时静默跳过。
+func chownIfPermitted(path string, uid, gid int) {
+	if runtime.GOOS == "windows" { return }
+	if err := os.Lchown(path, uid, gid); err != nil && !errors.Is(err, fs.ErrPermission) {
+		log.Printf("WARNING: Could not restore the owner of '%s': %v", path, err)
+	}
+}
+
+// replaceEntry 在目标旁边用 create 创建新条目，再原子地替换目标。
+func replaceEntry(dest string, create func(tmpPath string) error) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil { return err }
+	if current, err := os.Lstat(dest); err == nil && current.IsDir() {
+		return errors.New("a directory exists at the destination")
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".media-sorter-restore-*")
+	if err != nil { return err }
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	if err := create(tmpPath); err != nil { return err }
+	if err := os.Rename(tmpPath, dest); err != nil { os.Remove(tmpPath); return err }
+	// 如果 dest 原本就是同一个文件的硬链接，rename 什么也不做，临时链接会留下来
+	os.Remove(tmpPath)
+	return nil
+}
+
+// removeRenamedCopy 删除文件在处理时被改名后留下的副本；只处理普通文件。
+// 内容与清单记录的不同（或没有记录）时，该文件不是那次运行留下的副本，把它移到一旁而不是删除。
+// 本次还原已经写出的文件（处理时两个文件交换了名字）不会被触及。
+func removeRenamedCopy(targetDir, relPath string, c renamedCopy, restoredFiles map[string]bool) error {
+	if c.path == "" || c.path == relPath || restoredFiles[c.path] { return nil }
+	copyPath := filepath.Join(targetDir, c.path)
+	info, err := os.Lstat(copyPath)
+	if err != nil || !info.Mode().IsRegular() { return nil }
+	if !c.matches(targetDir) {
+		keptPath, err := moveAside(copyPath)
+		if err != nil { return fmt.Errorf("moving '%s' aside: %w", c.path, err) }
+		log.Printf("WARNING: '%s' is not the renamed copy of '%s' recorded in the manifest; kept it as '%s'.", c.path, relPath, filepath.Base(keptPath))
+		return nil
+	}
+	if err := os.Remove(copyPath); err != nil { return fmt.Errorf("removing renamed copy '%s': %w", c.path, err) }
+	fmt.Printf("  Removed renamed copy '%s' (restored as '%s').\n", c.path, relPath)
+	return nil
+}
+
+// matches 报告目标目录中的改名副本是否仍是处理完成时的内容。
+func (c renamedCopy) matches(targetDir string) bool {
+	if c.sha256 == "" { return false }
+	sum, err := fileSHA256(filepath.Join(targetDir, c.path))
+	return err == nil && sum == c.sha256
+}
+
+// moveAside 把文件改名为同一目录中未被占用的 <名字>_kept[N]<扩展名>，返回新路径。
+func moveAside(path string) (string, error) {
+	ext := filepath.Ext(path)
+	stem := strings.TrimSuffix(path, ext)
+	for n := 1; ; n++ {
+		candidate := stem + "_kept" + ext
+		if n > 1 { candidate = fmt.Sprintf("%s_kept%d%s", stem, n, ext) }
+		err := renameNoReplace(path, candidate)
+		if !errors.Is(err, fs.ErrExist) { return candidate, err }
+	}
+}
+
+func restoreRegularFile(dest string, hdr *tar.Header, r io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil { return err }
+	if current, err := os.Lstat(dest); err == nil && current.IsDir() {
+		return errors.New("a directory exists at the destination")
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".media-sorter-restore-*")
+	if err != nil { return err }
+	tmpPath := tmp.Name()
+	_, err = io.Copy(tmp, r)
+	if closeErr := tmp.Close(); err == nil { err = closeErr }
+	if err == nil { err = applyHeaderAttrs(tmpPath, hdr) }
+	if err == nil { err = os.Rename(tmpPath, dest) }
+	if err != nil { os.Remove(tmpPath); return err }
+	return nil
+}
+
+// accessTimeOf 返回条目的访问时间；旧格式的 tar 头没有记录时，使用修改时间代替。
+func accessTimeOf(hdr *tar.Header) time.Time {
+	if hdr.AccessTime.IsZero() { return hdr.ModTime }
+	return hdr.AccessTime
+}
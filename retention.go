@@ -0,0 +1,196 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"media-sorter/ui"
+)
+
+// runBackupCommand 实现 `media-sorter backup list|prune [options]` 子命令。
+func runBackupCommand(args []string) {
+	if len(args) == 0 || (args[0] != "list" && args[0] != "prune") {
+		log.Println("Error: Expected 'backup list' or 'backup prune'."); ui.ShowHelp(); os.Exit(1)
+	}
+	cfg := loadConfig()
+	flags := flag.NewFlagSet("backup "+args[0], flag.ExitOnError)
+	flags.Usage = ui.ShowHelp
+	backupDir := flags.String("backup-dir", "./media_backups", "Directory where backups are stored.")
+	dryRun := flags.Bool("dry-run", false, "Only list the backups that would be removed.")
+	autoConfirm := flags.Bool("yes", false, "Bypass the confirmation prompt.")
+	// 命令行参数覆盖配置文件中的保留策略
+	flags.IntVar(&cfg.Retention.KeepLast, "keep-last", cfg.Retention.KeepLast, "Keep the N most recent backups.")
+	flags.IntVar(&cfg.Retention.KeepDaily, "keep-daily", cfg.Retention.KeepDaily, "Keep the newest backup of each of the last N days.")
+	flags.IntVar(&cfg.Retention.KeepWeekly, "keep-weekly", cfg.Retention.KeepWeekly, "Keep the newest backup of each of the last N weeks.")
+	flags.IntVar(&cfg.Retention.KeepMonthly, "keep-monthly", cfg.Retention.KeepMonthly, "Keep the newest backup of each of the last N months.")
+	flags.StringVar(&cfg.Retention.MaxTotalSize, "max-total-size", cfg.Retention.MaxTotalSize, "Remove the oldest backups until the total size is below this limit (e.g. 50GB).")
+	flags.Parse(args[1:])
+
+	if args[0] == "list" {
+		showBackupList(*backupDir, cfg.Retention)
+		return
+	}
+	if !cfg.Retention.enabled() {
+		fmt.Println("No retention policy configured; nothing to prune. Set 'retention' in config.json or pass -keep-* / -max-total-size.")
+		return
+	}
+	fmt.Printf("Backups in '%s' that fall outside the retention policy:\n\n", *backupDir)
+	removed, freed, err := applyRetention(*backupDir, cfg.Retention, "", true)
+	if err != nil { log.Fatalf("ERROR: Could not apply the retention policy: %v", err) }
+	if removed == 0 { fmt.Println("  (none)"); return }
+	fmt.Printf("\n%d backups, %s in total.\n", removed, formatSize(freed))
+	if *dryRun { fmt.Println("\nDry run: no backups were removed."); return }
+
+	if !*autoConfirm {
+		if !ui.RequestConfirmation() { log.Println("Prune cancelled by user."); os.Exit(0) }
+	}
+	removed, freed, err = applyRetention(*backupDir, cfg.Retention, "", false)
+	if err != nil { log.Fatalf("ERROR: %v", err) }
+	fmt.Printf("\nPruned %d backups, freed %s.\n", removed, formatSize(freed))
+}
+
+// retentionPolicy 决定备份目录中保留哪些备份。所有数值为 0 表示不启用对应规则；
+// 全部为 0 时不会删除任何备份。
+type retentionPolicy struct {
+	KeepLast     int    `json:"keep_last"`      // 保留最近的 N 个备份
+	KeepDaily    int    `json:"keep_daily"`     // 最近 N 天中，每天保留最新的一个
+	KeepWeekly   int    `json:"keep_weekly"`    // 最近 N 周中，每周保留最新的一个
+	KeepMonthly  int    `json:"keep_monthly"`   // 最近 N 个月中，每月保留最新的一个
+	MaxTotalSize string `json:"max_total_size"` // 备份目录的总大小上限，如 "50GB"；超出时从最旧的开始删除
+}
+
+// enabled 报告是否配置了任何保留规则。
+func (p retentionPolicy) enabled() bool {
+	return p.KeepLast > 0 || p.KeepDaily > 0 || p.KeepWeekly > 0 || p.KeepMonthly > 0 || p.MaxTotalSize != ""
+}
+
+// pruneDecision 是对单个备份的保留判断。
+type pruneDecision struct {
+	Archive backupArchive
+	Keep    bool
+	Reason  string // 保留或删除的原因，用于 list/prune 的输出
+}
+
+// planPrune 按保留策略决定每个备份的去留，结果与 archives 顺序一致（按创建时间升序）。
+// 时间规则按源目录（完整路径）分组计算，使不同目录的备份互不挤占名额；总大小上限针对整个备份目录。
+// protect 中的备份（通常是刚刚创建的那个）无论如何都会保留。
+func planPrune(archives []backupArchive, p retentionPolicy, protect string) ([]pruneDecision, error) {
+	maxTotal, err := parseSize(p.MaxTotalSize)
+	if err != nil { return nil, err }
+
+	decisions := make([]pruneDecision, len(archives))
+	groups := make(map[string][]int)
+	for i, a := range archives {
+		decisions[i] = pruneDecision{Archive: a}
+		groups[a.sourceKey()] = append(groups[a.sourceKey()], i)
+	}
+	for _, idxs := range groups {
+		// 从最新的开始，依次套用各条规则
+		counts := map[string]int{}
+		seen := map[string]bool{}
+		for n := len(idxs) - 1; n >= 0; n-- {
+			d := &decisions[idxs[n]]
+			t := d.Archive.CreatedAt
+			year, week := t.ISOWeek()
+			rules := []struct {
+				name   string
+				limit  int
+				bucket string
+			}{
+				{"last", p.KeepLast, strconv.Itoa(n)},
+				{"daily", p.KeepDaily, t.Format("2006-01-02")},
+				{"weekly", p.KeepWeekly, fmt.Sprintf("%d-W%02d", year, week)},
+				{"monthly", p.KeepMonthly, t.Format("2006-01")},
+			}
+			var reasons []string
+			for _, rule := range rules {
+				key := rule.name + "/" + rule.bucket
+				if rule.limit == 0 || seen[key] || counts[rule.name] >= rule.limit { continue }
+				seen[key] = true
+				counts[rule.name]++
+				reasons = append(reasons, rule.name)
+			}
+			if len(reasons) > 0 { d.Keep, d.Reason = true, "keep "+strings.Join(reasons, ", ") }
+		}
+	}
+	// 只配置了大小上限时，时间规则不删除任何备份
+	if p.KeepLast == 0 && p.KeepDaily == 0 && p.KeepWeekly == 0 && p.KeepMonthly == 0 {
+		for i := range decisions { decisions[i].Keep, decisions[i].Reason = true, "" }
+	}
+	for i := range decisions {
+		if decisions[i].Archive.Path == protect { decisions[i].Keep, decisions[i].Reason = true, "current backup" }
+		if !decisions[i].Keep { decisions[i].Reason = "outside retention policy" }
+	}
+
+	if maxTotal > 0 {
+		var total int64
+		for _, d := range decisions {
+			if d.Keep { total += d.Archive.Size }
+		}
+		// 从最旧的开始删除，直到总大小不超过上限
+		for i := range decisions {
+			if total <= maxTotal { break }
+			d := &decisions[i]
+			if !d.Keep || d.Archive.Path == protect { continue }
+			d.Keep, d.Reason = false, "over max_total_size"
+			total -= d.Archive.Size
+		}
+	}
+	return decisions, nil
+}
+
+// removeBackup 删除一个备份归档（或快照目录）及其清单。
+func removeBackup(a backupArchive) error {
+	if err := os.RemoveAll(a.Path); err != nil { return err }
+	if err := os.Remove(manifestPathFor(a.Path)); err != nil && !os.IsNotExist(err) { return err }
+	return nil
+}
+
+// applyRetention 按策略清理备份目录，返回被删除的备份数量和释放的空间。
+// dryRun 为 true 时只打印将要删除的备份。
+func applyRetention(backupDir string, p retentionPolicy, protect string, dryRun bool) (int, int64, error) {
+	archives, err := listBackups(backupDir)
+	if err != nil { return 0, 0, err }
+	decisions, err := planPrune(archives, p, protect)
+	if err != nil { return 0, 0, err }
+
+	removed, freed := 0, int64(0)
+	for _, d := range decisions {
+		if d.Keep { continue }
+		if dryRun {
+			fmt.Printf("  Would remove '%s' (%s, %s)\n", d.Archive.Name, formatSize(d.Archive.Size), d.Reason)
+		} else {
+			if err := removeBackup(d.Archive); err != nil { return removed, freed, fmt.Errorf("could not remove '%s': %w", d.Archive.Name, err) }
+			fmt.Printf("  Removed '%s' (%s, %s)\n", d.Archive.Name, formatSize(d.Archive.Size), d.Reason)
+		}
+		removed++
+		freed += d.Archive.Size
+	}
+	return removed, freed, nil
+}
+
+// parseSize 解析 "500MB"、"50GB"、"1.5TiB" 这样的大小。单位按 1024 进制计算；空字符串表示不限制。
+func parseSize(s string) (int64, error) {
+	orig := s
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if s == "" { return 0, nil }
+	units := []struct {
+		suffix string
+		factor float64
+	}{
+		{"TIB", 1 << 40}, {"GIB", 1 << 30}, {"MIB", 1 << 20}, {"KIB", 1 << 10},
+		{"TB", 1 << 40}, {"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+		{"T", 1 << 40}, {"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+	}
+	factor := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) { s, factor = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.factor; break }
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 { return 0, fmt.Errorf("invalid size: %q", orig) }
+	return int64(n * factor), nil
+}
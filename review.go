@@ -0,0 +1,298 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"media-sorter/ui"
+)
+
+// defaultReviewThreshold 是配置中没有设置 low_confidence.threshold 时，review 命令列出文件的可信度阈值。
+const defaultReviewThreshold = confidenceFilename
+
+// reviewRangeSeparators 是用户输入日期范围时可以使用的分隔符。
+var reviewRangeSeparators = []string{"..", " to "}
+
+// reviewGroup 是同一文件夹中等待人工指定日期的文件。
+type reviewGroup struct {
+	folder      string         // 文件复核后所在的文件夹（复核目录中的文件回到原来的位置）
+	files       []*plannedFile // 按文件名排序
+	trusted     []*plannedFile // 同一文件夹中时间可信的文件，用于推断日期
+	suggestion  string         // 推断出的日期或日期范围，格式与用户输入相同
+	suggestedBy string         // 推断的依据
+}
+
+// runReview 实现 `media-sorter review [options] TARGET_DIRECTORY` 子命令：
+// 按文件夹列出时间可信度过低的文件（包括复核目录中的文件），由用户输入日期或日期范围，
+// 然后改写元数据中的时间标签，按新时间重命名并同步 mtime。复核目录中的文件会移回原来的文件夹。
+// 修改之前与常规运行一样先创建并校验备份，restore 可以撤销复核。
+func runReview(args []string) {
+	flags := flag.NewFlagSet("review", flag.ExitOnError)
+	flags.Usage = ui.ShowHelp
+	threshold := flags.String("threshold", "", "List files whose confidence is below this level (default: low_confidence.threshold in config.json, or 'filename').")
+	maxDepth := flags.Int("depth", -1, "Maximum depth for directory traversal. -1 for infinite, 0 for current directory only.")
+	backupDir := flags.String("backup-dir", "./media_backups", "Directory to store backups.")
+	exiftoolOverridePath := flags.String("exiftool-path", "", "Manually specify the full path to the exiftool executable.")
+	noBackup := flags.Bool("no-backup", false, "Disable the backup before the files are changed.")
+	dryRun := flags.Bool("dry-run", false, "Only list what would be dated and renamed.")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 { log.Println("Error: Expected exactly one TARGET_DIRECTORY."); ui.ShowHelp(); os.Exit(1) }
+
+	cfg := loadConfig()
+	imageExtMap := sliceToMap(cfg.SupportedImageExtensions)
+	videoExtMap := sliceToMap(cfg.SupportedVideoExtensions)
+	audioExtMap := sliceToMap(cfg.SupportedAudioExtensions)
+	targetLocation, err := parseTimeZone(cfg.TargetTimezone)
+	if err != nil { log.Fatalf("FATAL: Invalid 'target_timezone' in config.json: '%s'. Error: %v", cfg.TargetTimezone, err) }
+	zones, err := newTimeZones(cfg, targetLocation)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	sanity, err := newTimestampSanity(cfg.TimestampSanity)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	review, err := newReviewPolicy(cfg.LowConfidence)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	level := defaultReviewThreshold
+	if review.enabled { level = review.threshold }
+	if *threshold != "" {
+		if level, err = parseConfidence(*threshold); err != nil { log.Fatalf("ERROR: Invalid -threshold: %v", err) }
+	}
+	exiftoolPath := findExiftool(*exiftoolOverridePath)
+	if exiftoolPath == "" { log.Fatalf("FATAL: 'exiftool' is required to write the assigned dates into the metadata.") }
+
+	absPath, err := filepath.Abs(flags.Arg(0))
+	if err != nil { log.Fatalf("ERROR: Failed to resolve absolute path for target directory '%s': %v", flags.Arg(0), err) }
+	if info, err := os.Stat(absPath); err != nil || !info.IsDir() {
+		log.Fatalf("ERROR: Invalid target directory: '%s'. Directory does not exist or is not a directory.", absPath)
+	}
+
+	fmt.Println("\nAnalyzing files...")
+	// 复核目录中的文件正是要复核的对象，这里不能跳过它
+	reviewRoot := ""
+	if folder := cfg.LowConfidence.reviewFolder(); folder != "" { reviewRoot = filepath.Join(absPath, folder) }
+	scanCfg := cfg
+	scanCfg.LowConfidence.Folder = ""
+	candidates, err := buildPlan(absPath, *maxDepth, exiftoolPath, scanCfg, imageExtMap, videoExtMap, audioExtMap, zones, nil, sanity, reviewPolicy{})
+	if err != nil { log.Fatalf("File processing failed during directory traversal: %v", err) }
+	groups := groupReviewFiles(candidates, absPath, reviewRoot, level)
+	if len(groups) == 0 { fmt.Printf("\nNo files with a confidence below '%s' were found.\n", level); return }
+
+	var plan []*plannedFile
+	for i, group := range groups {
+		ui.ShowReviewGroup(i+1, len(groups), group.display(absPath))
+		answer := askReviewDate(group)
+		if answer == "q" { break }
+		if answer == "" { continue }
+		from, to, _ := parseReviewAnswer(answer)
+		plan = append(plan, assignReviewTimes(group, from, to, zones)...)
+	}
+	if len(plan) == 0 { fmt.Println("\nNo dates were assigned; nothing to do."); return }
+	resolveCollisions(plan)
+
+	fmt.Printf("\n%d files will be dated and renamed:\n\n", len(plan))
+	for _, pf := range plan {
+		rel, _ := filepath.Rel(absPath, pf.TargetPath)
+		fmt.Printf("  %s -> %s   (%s)\n", filepath.Base(pf.Path), rel, pf.Time.Format("2006-01-02 15:04:05"))
+	}
+	if *dryRun { fmt.Println("\nDry run: no files were changed."); return }
+	preflight := runPreflight(absPath, *backupDir, !*noBackup, cfg.BackupMode, plan)
+	if len(preflight.Problems) > 0 {
+		for _, problem := range preflight.Problems { log.Printf("ERROR: %s", problem) }
+		log.Fatalf("ERROR: Preflight checks failed. No files were modified.")
+	}
+	if !ui.RequestConfirmation() { log.Println("Operation cancelled by user."); os.Exit(0) }
+
+	var archivePath string
+	var manifest *backupManifest
+	if !*noBackup { archivePath, manifest = backupBeforeChanges(absPath, *backupDir, cfg, plan) }
+
+	if err := createTargetDirs(plan); err != nil { log.Fatalf("ERROR: Could not create the destination folders: %v", err) }
+	renameFiles(plan)
+	saveRenames(archivePath, manifest, plan)
+	failed := 0
+	for _, pf := range plan {
+		if err := reviewFile(pf, exiftoolPath, cfg, imageExtMap); err != nil {
+			log.Printf("  └─ ERROR: %v\n", err)
+			failed++
+		}
+	}
+	saveRenamedChecksums(archivePath, manifest)
+	// 复核目录中被清空的文件夹没有继续保留的意义
+	if reviewRoot != "" {
+		for _, pf := range plan { removeEmptyDirs(filepath.Dir(pf.Path), reviewRoot) }
+	}
+	fmt.Printf("\nDated %d files (%d failed).\n", len(plan)-failed, failed)
+}
+
+// removeEmptyDirs 从 dir 开始逐级向上删除空文件夹，直到 stop（包括 stop 本身）。dir 不在 stop 之内时什么也不做。
+func removeEmptyDirs(dir, stop string) {
+	for {
+		rel, err := filepath.Rel(stop, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) { return }
+		if os.Remove(dir) != nil || rel == "." { return }
+		dir = filepath.Dir(dir)
+	}
+}
+
+// groupReviewFiles 挑出可信度低于 level 的文件和复核目录中的所有文件，按它们复核后所在的文件夹分组，
+// 并根据同一文件夹中时间可信的文件推断日期。
+func groupReviewFiles(candidates []*plannedFile, rootDir, reviewRoot string, level confidence) []*reviewGroup {
+	byFolder := make(map[string]*reviewGroup)
+	var order []string
+	group := func(folder string) *reviewGroup {
+		if g, ok := byFolder[folder]; ok { return g }
+		g := &reviewGroup{folder: folder}
+		byFolder[folder] = g
+		order = append(order, folder)
+		return g
+	}
+
+	for _, pf := range candidates {
+		folder := filepath.Dir(pf.Path)
+		quarantined := false
+		if reviewRoot != "" {
+			if rel, err := filepath.Rel(reviewRoot, folder); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				folder, quarantined = filepath.Join(rootDir, rel), true
+			}
+		}
+		g := group(folder)
+		if quarantined || pf.Confidence < level {
+			g.files = append(g.files, pf)
+		} else {
+			g.trusted = append(g.trusted, pf)
+		}
+	}
+
+	var groups []*reviewGroup
+	for _, folder := range order {
+		g := byFolder[folder]
+		if len(g.files) == 0 { continue }
+		sort.SliceStable(g.files, func(i, j int) bool { return filepath.Base(g.files[i].Path) < filepath.Base(g.files[j].Path) })
+		g.suggestion, g.suggestedBy = inferFolderDate(g.trusted, g.folder, rootDir)
+		groups = append(groups, g)
+	}
+	return groups
+}
+
+// inferFolderDate 根据同一文件夹中时间可信的文件推断日期：都在同一天时返回该日期，否则返回它们的日期范围。
+// 没有这样的文件时，使用文件夹（或上级文件夹）名中的日期所覆盖的范围。
+func inferFolderDate(trusted []*plannedFile, folder, rootDir string) (string, string) {
+	if len(trusted) == 0 {
+		wall, precision, name, ok := folderDate(folder, rootDir)
+		if !ok { return "", "" }
+		return folderDateRange(wall, precision), fmt.Sprintf("from the folder name '%s'", name)
+	}
+	first, last := trusted[0].Time, trusted[0].Time
+	for _, pf := range trusted[1:] {
+		if pf.Time.Before(first) { first = pf.Time }
+		if pf.Time.After(last) { last = pf.Time }
+	}
+	by := fmt.Sprintf("from %d dated files in this folder", len(trusted))
+	from, to := first.Format("2006-01-02"), last.Format("2006-01-02")
+	if from == to { return from, by }
+	return from + ".." + to, by
+}
+
+// display 把分组转换为 ui 包中用于显示的结构。
+func (g *reviewGroup) display(rootDir string) ui.ReviewGroup {
+	rel, err := filepath.Rel(rootDir, g.folder)
+	if err != nil { rel = g.folder }
+	group := ui.ReviewGroup{Folder: rel, Suggestion: g.suggestion, SuggestionSource: g.suggestedBy}
+	for _, pf := range g.files {
+		group.Files = append(group.Files, ui.ReviewFile{
+			Name:       filepath.Base(pf.Path),
+			Time:       pf.Time.Format("2006-01-02 15:04:05"),
+			Source:     pf.Source,
+			Confidence: pf.Confidence.String(),
+		})
+	}
+	return group
+}
+
+// askReviewDate 反复询问，直到得到一个有效的日期或范围（返回用户输入）、跳过（返回空字符串）或停止（返回 "q"）。
+func askReviewDate(g *reviewGroup) string {
+	for {
+		answer := ui.RequestReviewDate(g.suggestion)
+		switch strings.ToLower(answer) {
+		case "q", "quit":
+			return "q"
+		case "s", "skip":
+			return ""
+		case "":
+			return g.suggestion
+		}
+		if _, _, err := parseReviewAnswer(answer); err != nil {
+			fmt.Printf("  └─ ERROR: %v\n", err)
+			continue
+		}
+		return answer
+	}
+}
+
+// parseReviewAnswer 把用户输入的日期（YYYY-MM-DD [HH:MM[:SS]]）或范围（FROM..TO）解析为以 UTC 表示的钟面时间。
+// 单个日期时 from 与 to 相同；范围的结束只有日期时，延伸到那一天的最后一秒，使整天都在范围内。
+func parseReviewAnswer(answer string) (time.Time, time.Time, error) {
+	for _, sep := range reviewRangeSeparators {
+		fromStr, toStr, ok := strings.Cut(answer, sep)
+		if !ok { continue }
+		from, _, err := parseWallTime(fromStr)
+		if err != nil { return time.Time{}, time.Time{}, err }
+		to, dateOnly, err := parseWallTime(toStr)
+		if err != nil { return time.Time{}, time.Time{}, err }
+		if dateOnly { to = to.AddDate(0, 0, 1).Add(-time.Second) }
+		if to.Before(from) { return time.Time{}, time.Time{}, fmt.Errorf("the range ends (%s) before it starts (%s)", strings.TrimSpace(toStr), strings.TrimSpace(fromStr)) }
+		return from, to, nil
+	}
+	t, _, err := parseWallTime(answer)
+	return t, t, err
+}
+
+// assignReviewTimes 把用户指定的时间分配给一组文件。指定的是范围时，按文件名顺序把文件均匀分布在范围内，
+// 以保留扫描或导出的先后顺序；指定的是单个时间时，所有文件使用同一时间，由冲突后缀保持文件名顺序。
+// 钟面时间按行程中的时区解释，没有匹配的行程时使用目标时区。
+func assignReviewTimes(g *reviewGroup, from, to time.Time, zones *timeZones) []*plannedFile {
+	step := time.Duration(0)
+	if n := len(g.files); n > 1 { step = to.Sub(from) / time.Duration(n-1) }
+
+	for i, pf := range g.files {
+		wall := from.Add(step * time.Duration(i)).Truncate(time.Second)
+		loc, _, ok := zones.itineraryZone(pf.Meta, wall, true)
+		if !ok { loc = zones.target }
+		t := time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), 0, loc)
+		at := authoritativeTime{Time: t}
+		// 图片的时间标签按指定日期所在的时区写入，而不是规划时读到的元数据的时区
+		if pf.IsImage { pf.TagLocation = loc }
+		pf.Time = t.In(zones.filenameLocation(at, loc))
+		pf.Source = "manual date"
+		pf.IsAuthoritative = false
+		pf.Correction = historyManualDate + " " + wall.Format("2006-01-02 15:04:05")
+		pf.IdealPath = filepath.Join(g.folder, generateNewFilename(pf.Time, pf.Prefix, pf.extension, false))
+	}
+	return g.files
+}
+
+// reviewFile 改写一个已经重命名的文件的时间标签并同步 mtime。
+func reviewFile(pf *plannedFile, exiftoolPath string, cfg Config, imageExtMap map[string]bool) error {
+	fmt.Println("----------------------------------------")
+	fmt.Printf("Dating: '%s'\n", filepath.Base(pf.Path))
+	if pf.renameErr != nil { return fmt.Errorf("failed to rename the file to '%s': %w", filepath.Base(pf.TargetPath), pf.renameErr) }
+	if pf.renamed { fmt.Printf("  └─ INFO: Renamed to '%s'\n", filepath.Base(pf.currentPath)) }
+	// 硬链接快照中的文件先换成独立副本，否则改写会同时改掉备份
+	if pf.sharesInode {
+		if err := breakHardlink(pf.currentPath); err != nil { return fmt.Errorf("failed to detach the file from the snapshot, skipped to keep the backup intact: %w", err) }
+	}
+	// 不改写音频文件的标签，只重命名并同步 mtime
+	if !pf.IsAudio {
+		if err := enrichMetadata(pf.currentPath, pf.metadataTime(), pf.Meta, pf.Correction, exiftoolPath, cfg, pf.IsImage); err != nil {
+			return fmt.Errorf("failed to write metadata: %w", err)
+		}
+		fmt.Println("  └─ INFO: Metadata timestamps set to the assigned date.")
+	}
+	if err := syncFileTimestamp(pf.currentPath, pf.Time); err != nil { return fmt.Errorf("failed to sync mtime: %w", err) }
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseReviewAnswer(t *testing.T) {
+	day := func(d, h, m, s int) time.Time { return time.Date(2019, 7, d, h, m, s, 0, time.UTC) }
+	tests := []struct {
+		answer   string
+		from, to time.Time
+	}{
+		{"2019-07-14", day(14, 0, 0, 0), day(14, 0, 0, 0)},
+		{"2019-07-14 15:30", day(14, 15, 30, 0), day(14, 15, 30, 0)},
+		// 只有日期的结束延伸到那一天的最后一秒
+		{"2019-07-01..2019-07-31", day(1, 0, 0, 0), day(31, 23, 59, 59)},
+		{"2019-07-14..2019-07-14", day(14, 0, 0, 0), day(14, 23, 59, 59)},
+		{"2019-07-01 to 2019-07-02", day(1, 0, 0, 0), day(2, 23, 59, 59)},
+		{"2019-07-14 09:00..2019-07-14 18:00", day(14, 9, 0, 0), day(14, 18, 0, 0)},
+		{"2019-07-14 09:00..2019-07-14", day(14, 9, 0, 0), day(14, 23, 59, 59)},
+	}
+	for _, tt := range tests {
+		from, to, err := parseReviewAnswer(tt.answer)
+		if err != nil { t.Errorf("%s: %v", tt.answer, err); continue }
+		if !from.Equal(tt.from) || !to.Equal(tt.to) { t.Errorf("%s: %v .. %v, want %v .. %v", tt.answer, from, to, tt.from, tt.to) }
+	}
+	for _, answer := range []string{"2019-07-31..2019-07-01", "2019-07-14 18:00..2019-07-14 09:00", "July", "2019-07-01..soon"} {
+		if _, _, err := parseReviewAnswer(answer); err == nil { t.Errorf("%s: accepted", answer) }
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// timestampSanityConfig 是配置文件中的时间合理性检查。
+type timestampSanityConfig struct {
+	MinYear       int      `json:"min_year"`        // 早于这一年的时间视为无效，0 表示不检查
+	MaxFutureSkew string   `json:"max_future_skew"` // 晚于当前时间超过这个时长视为无效，如 "24h"；为空表示不检查
+	BogusValues   []string `json:"bogus_values"`    // 额外的已知占位时间，补充在内置列表之后
+}
+
+// defaultBogusTimestamps 是相机和系统常见的默认时间：时钟未设置的相机、Unix 纪元、QuickTime 纪元和 FAT 纪元。
+var defaultBogusTimestamps = []string{
+	"2000:01:01 00:00:00",
+	"1970:01:01 00:00:00",
+	"1904:01:01 00:00:00",
+	"1980:01:01 00:00:00",
+}
+
+const bogusTimestampLayout = "2006:01:02 15:04:05"
+
+// timestampSanity 是解析后的检查规则。占位时间按钟面读数比较，忽略时区和亚秒。
+type timestampSanity struct {
+	minYear   int
+	maxFuture time.Duration // 0 表示不检查
+	bogus     map[string]bool // 钟面读数，格式为 bogusTimestampLayout
+	now       time.Time
+}
+
+// newTimestampSanity 校验并解析配置中的检查规则。
+func newTimestampSanity(cfg timestampSanityConfig) (*timestampSanity, error) {
+	s := &timestampSanity{minYear: cfg.MinYear, bogus: make(map[string]bool), now: time.Now()}
+	if cfg.MaxFutureSkew != "" {
+		d, err := time.ParseDuration(cfg.MaxFutureSkew)
+		if err != nil || d <= 0 { return nil, fmt.Errorf("invalid 'timestamp_sanity.max_future_skew': '%s'", cfg.MaxFutureSkew) }
+		s.maxFuture = d
+	}
+	for _, value := range append(append([]string{}, defaultBogusTimestamps...), cfg.BogusValues...) {
+		t, err := parseBogusTimestamp(value)
+		if err != nil { return nil, fmt.Errorf("invalid 'timestamp_sanity.bogus_values' entry: %w", err) }
+		s.bogus[t.Format(bogusTimestampLayout)] = true
+	}
+	return s, nil
+}
+
+// parseBogusTimestamp 接受 EXIF 格式（2000:01:01 00:00:00）或配置中其他地方使用的格式（2000-01-01 00:00:00）。
+func parseBogusTimestamp(value string) (time.Time, error) {
+	if t, err := parseExifTime(strings.TrimSpace(value), time.UTC); err == nil { return t, nil }
+	t, _, err := parseWallTime(value)
+	return t, err
+}
+
+// check 返回时间不合理的原因；时间合理时返回空字符串。s 为 nil 时不做任何检查。
+func (s *timestampSanity) check(t time.Time) string {
+	if s == nil { return "" }
+	if s.bogus[t.Format(bogusTimestampLayout)] { return "known placeholder date" }
+	if s.minYear > 0 && t.Year() < s.minYear { return fmt.Sprintf("before %d", s.minYear) }
+	if s.maxFuture > 0 && t.After(s.now.Add(s.maxFuture)) { return "in the future" }
+	return ""
+}
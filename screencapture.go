@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// screenCaptureConfig 是配置文件中识别截图和录屏的规则。
+type screenCaptureConfig struct {
+	Enabled          bool   `json:"enabled"`
+	ScreenshotPrefix string `json:"screenshot_prefix"` // 截图使用的前缀，代替 image_prefix
+	RecordingPrefix  string `json:"recording_prefix"`  // 录屏使用的前缀，代替 video_prefix
+	Folder           string `json:"folder"`            // 非空时移入目标目录下的这个文件夹（保留子目录结构）
+}
+
+// 识别截图和录屏额外需要读取的标签。
+var (
+	// iOS 和 macOS 的截图在 UserComment 中写入 "Screenshot"；iOS 的截图还带有 XMP-photoshop:DateCreated，但没有相机厂商
+	screenCaptureImageTags = []string{"UserComment", "XMP-photoshop:DateCreated"}
+	// Android 录制的视频都带有 com.android.version，只有相机拍摄的视频才有 com.android.capture.fps
+	screenCaptureVideoTags = []string{"AndroidVersion", "AndroidCaptureFPS"}
+)
+
+// screenshotNames 和 screenRecordingNames 是截图、录屏工具常用的文件名（小写，"_" 和 "-" 视为空格）。
+var (
+	screenshotNames = []string{
+		"screenshot", "screen shot", "bildschirmfoto", "capture d'écran", "capture d’écran", "captura de pantalla",
+		"schermafbeelding", "istantanea schermo", "captura de ecrã", "截屏", "截图", "屏幕截图", "スクリーンショット", "스크린샷",
+	}
+	screenRecordingNames = []string{
+		"screen recording", "screenrecording", "screenrecord", "screen record", "rpreplay", "bildschirmaufnahme",
+		"enregistrement de l'écran", "enregistrement de l’écran", "grabación de pantalla", "schermopname", "录屏", "屏幕录制", "画面収録",
+	}
+)
+
+// checkScreenCaptures 校验配置中的截图和录屏规则。
+func checkScreenCaptures(cfg screenCaptureConfig) error {
+	if !cfg.Enabled { return nil }
+	if cfg.ScreenshotPrefix == "" || cfg.RecordingPrefix == "" {
+		return fmt.Errorf("'screen_captures.screenshot_prefix' and 'screen_captures.recording_prefix' must not be empty")
+	}
+	if cfg.Folder != "" && !isInsideFolder(cfg.Folder) {
+		return fmt.Errorf("invalid 'screen_captures.folder': '%s', expected a directory name inside the target directory", cfg.Folder)
+	}
+	return nil
+}
+
+// prefixFor 返回截图（图片）或录屏（视频）使用的前缀。
+func (cfg screenCaptureConfig) prefixFor(isImage bool) string {
+	if isImage { return cfg.ScreenshotPrefix }
+	return cfg.RecordingPrefix
+}
+
+// screenCaptureReason 判断文件是否是截图或录屏，返回判断的依据。haveMetadata 为 false（没有 exiftool）时只根据文件名判断。
+func screenCaptureReason(pf *plannedFile, cfg screenCaptureConfig, isImage, haveMetadata bool) (string, bool) {
+	// collectMediaFiles 已经为之前识别过的文件保留了截图或录屏的前缀
+	if pf.Prefix == cfg.prefixFor(isImage) { return "named by a previous run", true }
+
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(pf.Path), filepath.Ext(pf.Path)))
+	name = strings.NewReplacer("_", " ", "-", " ").Replace(name)
+	names := screenRecordingNames
+	if isImage { names = screenshotNames }
+	for _, n := range names {
+		if strings.Contains(name, n) { return "filename", true }
+	}
+	// Pixel 的录屏工具把文件命名为 screen-20240101-120000.mp4
+	if !isImage && strings.HasPrefix(name, "screen 20") { return "filename", true }
+	if !haveMetadata { return "", false }
+
+	meta := pf.Meta
+	if isImage {
+		switch {
+		case strings.EqualFold(meta.Get("UserComment"), "Screenshot"):
+			return "UserComment", true
+		case meta.Get("Make") != "" || meta.Get("Model") != "":
+			return "", false
+		case strings.EqualFold(filepath.Ext(pf.Path), ".png"):
+			return "PNG without camera Make", true
+		case meta.Get("XMP-photoshop:DateCreated") != "":
+			return "XMP-photoshop:DateCreated without camera Make", true
+		}
+		return "", false
+	}
+	if meta.Get("AndroidVersion") != "" && meta.Get("AndroidCaptureFPS") == "" && meta.Get("Make") == "" && meta.Get("Model") == "" {
+		return "Android video without camera tags", true
+	}
+	return "", false
+}
+
+// applyScreenCapture 为截图和录屏换用单独的前缀，配置了文件夹时把理想路径移入该文件夹。
+func applyScreenCapture(pf *plannedFile, rootDir string, cfg screenCaptureConfig, haveMetadata bool) {
+	isImage := pf.IsImage
+	reason, ok := screenCaptureReason(pf, cfg, isImage, haveMetadata)
+	if !ok { return }
+	kind := "screen recording"
+	if isImage { kind = "screenshot" }
+	pf.Prefix = cfg.prefixFor(isImage)
+	pf.ScreenCapture = true
+
+	dir := filepath.Dir(pf.Path)
+	if cfg.Folder != "" { dir = destinationDir(rootDir, cfg.Folder, dir) }
+	pf.IdealPath = filepath.Join(dir, generateNewFilename(pf.Time, pf.Prefix, pf.extension, pf.IsAuthoritative))
+	fmt.Printf("  └─ INFO: Detected as a %s (%s).\n", kind, reason)
+}
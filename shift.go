@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"media-sorter/ui"
+)
+
+// shiftJournalName 是 shift 命令在备份目录中追加记录的日志文件，每行一个 JSON 对象。
+// 日志保存了每个文件原来的名字和时间，必要时可以据此手动撤销。
+// 修改任何文件之前先为每个文件写入一行 pending 记录，处理完成后再追加一行 done 或 failed 记录，
+// 因此中途中断时，只有 pending 记录的文件可能处于任意中间状态。
+const shiftJournalName = "shift_journal.jsonl"
+
+// shift 日志记录的状态。
+const (
+	shiftPending = "pending"
+	shiftDone    = "done"
+	shiftFailed  = "failed"
+)
+
+// shiftJournalEntry 是日志中的一行。
+type shiftJournalEntry struct {
+	ShiftedAt    string `json:"shifted_at"`
+	Path         string `json:"path"`
+	NewPath      string `json:"new_path"`
+	OriginalTime string `json:"original_time"`
+	ShiftedTime  string `json:"shifted_time"`
+	Delta        string `json:"delta"`
+	Status       string `json:"status"`
+	Error        string `json:"error,omitempty"`
+}
+
+// runShift 实现 `media-sorter shift [options] TARGET_DIRECTORY` 子命令：
+// 把选中文件的时间平移一个固定的量，改写元数据中的时间标签，按新时间重命名并同步 mtime。
+func runShift(args []string) {
+	flags := flag.NewFlagSet("shift", flag.ExitOnError)
+	flags.Usage = ui.ShowHelp
+	by := flags.String("by", "", "Shift by this duration, e.g. +1h12m30s or -45s.")
+	ref := flags.String("ref", "", "Reference file whose correct time is given by -to.")
+	to := flags.String("to", "", "The correct time of the reference file (YYYY-MM-DD HH:MM:SS, as shown in its filename).")
+	cameraMake := flags.String("make", "", "Only shift files whose Make matches (case-insensitive).")
+	cameraModel := flags.String("model", "", "Only shift files whose Model matches (case-insensitive).")
+	serial := flags.String("serial", "", "Only shift files whose SerialNumber matches (case-insensitive).")
+	maxDepth := flags.Int("depth", -1, "Maximum depth for directory traversal. -1 for infinite, 0 for current directory only.")
+	backupDir := flags.String("backup-dir", "./media_backups", "Directory where the shift journal is written.")
+	exiftoolOverridePath := flags.String("exiftool-path", "", "Manually specify the full path to the exiftool executable.")
+	dryRun := flags.Bool("dry-run", false, "Only list what would be shifted.")
+	autoConfirm := flags.Bool("yes", false, "Bypass the confirmation prompt.")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 { log.Println("Error: Expected exactly one TARGET_DIRECTORY."); ui.ShowHelp(); os.Exit(1) }
+	if (*by == "") == (*ref == "") { log.Fatalf("ERROR: Specify either -by, or -ref together with -to.") }
+	if *ref != "" && *to == "" { log.Fatalf("ERROR: -ref requires -to, the correct time of the reference file.") }
+
+	cfg := loadConfig()
+	imageExtMap := sliceToMap(cfg.SupportedImageExtensions)
+	videoExtMap := sliceToMap(cfg.SupportedVideoExtensions)
+	audioExtMap := sliceToMap(cfg.SupportedAudioExtensions)
+	targetLocation, err := parseTimeZone(cfg.TargetTimezone)
+	if err != nil { log.Fatalf("FATAL: Invalid 'target_timezone' in config.json: '%s'. Error: %v", cfg.TargetTimezone, err) }
+	zones, err := newTimeZones(cfg, targetLocation)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	sanity, err := newTimestampSanity(cfg.TimestampSanity)
+	if err != nil { log.Fatalf("FATAL: %v", err) }
+	exiftoolPath := findExiftool(*exiftoolOverridePath)
+	if exiftoolPath == "" { log.Fatalf("FATAL: 'exiftool' is required to shift timestamps stored in metadata.") }
+
+	absPath, err := filepath.Abs(flags.Arg(0))
+	if err != nil { log.Fatalf("ERROR: Failed to resolve absolute path for target directory '%s': %v", flags.Arg(0), err) }
+	if info, err := os.Stat(absPath); err != nil || !info.IsDir() {
+		log.Fatalf("ERROR: Invalid target directory: '%s'. Directory does not exist or is not a directory.", absPath)
+	}
+
+	var delta time.Duration
+	if *by != "" {
+		if delta, err = time.ParseDuration(*by); err != nil { log.Fatalf("ERROR: Invalid -by duration '%s': %v", *by, err) }
+	} else {
+		kind, _, _ := classifyFile(*ref, imageExtMap, videoExtMap, audioExtMap)
+		refFile := &plannedFile{Path: *ref, IsImage: kind == kindImage, IsAudio: kind == kindAudio, currentPath: *ref}
+		if !planFile(refFile, exiftoolPath, zones, nil, nil, sanity) { log.Fatalf("ERROR: Could not read the time of reference file '%s'.", *ref) }
+		if refFile.Confidence < confidenceNaive { log.Fatalf("ERROR: Reference file '%s' has no timestamp in its metadata.", *ref) }
+		correct, _, err := parseWallTime(*to)
+		if err != nil { log.Fatalf("ERROR: Invalid -to time: %v", err) }
+		delta = correct.Sub(wallClock(refFile.Time))
+		// 没有指定相机时，只平移与参考文件同一台相机拍摄的文件
+		if *cameraMake == "" && *cameraModel == "" && *serial == "" {
+			*cameraMake, *cameraModel = refFile.Meta.Get("Make"), refFile.Meta.Get("Model")
+			fmt.Printf("\nReference camera: %s %s (pass -make/-model/-serial to choose other files).\n", *cameraMake, *cameraModel)
+		}
+	}
+	if delta == 0 { fmt.Println("The shift is zero; nothing to do."); return }
+	fmt.Printf("\nShifting by %s.\n", describeOffset(delta))
+
+	fmt.Println("\nAnalyzing files...")
+	// 平移是用户明确要求的修改，不按可信度把文件移入复核目录
+	candidates, err := buildPlan(absPath, *maxDepth, exiftoolPath, cfg, imageExtMap, videoExtMap, audioExtMap, zones, nil, sanity, reviewPolicy{})
+	if err != nil { log.Fatalf("File processing failed during directory traversal: %v", err) }
+	plan := selectShiftFiles(candidates, delta, *cameraMake, *cameraModel, *serial)
+	if len(plan) == 0 { fmt.Println("\nNo files match the selection."); return }
+
+	fmt.Printf("\n%d files will be shifted by %s:\n\n", len(plan), describeOffset(delta))
+	for _, pf := range plan {
+		fmt.Printf("  %s -> %s   (%s -> %s)\n", filepath.Base(pf.Path), filepath.Base(pf.TargetPath),
+			pf.Time.Add(-delta).Format("2006-01-02 15:04:05"), pf.Time.Format("2006-01-02 15:04:05"))
+	}
+	if *dryRun { fmt.Println("\nDry run: no files were changed."); return }
+	if !*autoConfirm {
+		if !ui.RequestConfirmation() { log.Println("Operation cancelled by user."); os.Exit(0) }
+	}
+
+	if err := os.MkdirAll(*backupDir, 0755); err != nil { log.Fatalf("ERROR: Could not create directory '%s' for the shift journal: %v", *backupDir, err) }
+	journalPath := filepath.Join(*backupDir, shiftJournalName)
+	journal, err := os.OpenFile(journalPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil { log.Fatalf("ERROR: Could not open the shift journal: %v", err) }
+	defer journal.Close()
+	// 先记录全部计划，日志写不进去时不修改任何文件
+	for _, pf := range plan {
+		if err := writeShiftJournal(journal, pf, delta, shiftPending, nil); err != nil { log.Fatalf("ERROR: Could not write to the shift journal, no files were changed: %v", err) }
+	}
+
+	renameFiles(plan)
+	failed := 0
+	for _, pf := range plan {
+		status, shiftErr := shiftDone, shiftFile(pf, exiftoolPath, cfg, imageExtMap)
+		if shiftErr != nil {
+			log.Printf("  └─ ERROR: %v\n", shiftErr)
+			failed++
+			status = shiftFailed
+		}
+		if err := writeShiftJournal(journal, pf, delta, status, shiftErr); err != nil { log.Printf("WARNING: Could not write to the shift journal: %v", err) }
+	}
+	fmt.Printf("\nShifted %d files (%d failed). Journal: %s\n", len(plan)-failed, failed, journalPath)
+}
+
+// selectShiftFiles 从计划中挑出要平移的文件（只有元数据中带时间的文件），把它们的时间加上 delta，
+// 并按新时间重新计算文件名和冲突后缀。
+func selectShiftFiles(candidates []*plannedFile, delta time.Duration, cameraMake, cameraModel, serial string) []*plannedFile {
+	var plan []*plannedFile
+	for _, pf := range candidates {
+		if cameraMake != "" && !strings.EqualFold(cameraMake, pf.Meta.Get("Make")) { continue }
+		if cameraModel != "" && !strings.EqualFold(cameraModel, pf.Meta.Get("Model")) { continue }
+		if serial != "" && !strings.EqualFold(serial, pf.Meta.Get("SerialNumber")) { continue }
+		if pf.IsAudio {
+			fmt.Printf("  └─ INFO: Skipping '%s': audio tags are not rewritten.\n", filepath.Base(pf.Path))
+			continue
+		}
+		if pf.Confidence < confidenceNaive {
+			fmt.Printf("  └─ INFO: Skipping '%s': no timestamp in its metadata.\n", filepath.Base(pf.Path))
+			continue
+		}
+		pf.Time = pf.Time.Add(delta)
+		pf.Source = fmt.Sprintf("%s shifted by %s", pf.Source, describeOffset(delta))
+		pf.Correction = historyShift + " " + describeOffset(delta)
+		pf.IdealPath = filepath.Join(filepath.Dir(pf.Path), generateNewFilename(pf.Time, pf.Prefix, pf.extension, pf.IsAuthoritative))
+		plan = append(plan, pf)
+	}
+	resolveCollisions(plan)
+	return plan
+}
+
+// shiftFile 改写一个已经重命名的文件的时间标签并同步 mtime。
+func shiftFile(pf *plannedFile, exiftoolPath string, cfg Config, imageExtMap map[string]bool) error {
+	fmt.Println("----------------------------------------")
+	fmt.Printf("Shifting: '%s'\n", filepath.Base(pf.Path))
+	if pf.renameErr != nil { return fmt.Errorf("failed to rename the file to '%s': %w", filepath.Base(pf.TargetPath), pf.renameErr) }
+	if pf.renamed { fmt.Printf("  └─ INFO: Renamed to '%s'\n", filepath.Base(pf.currentPath)) }
+	if err := enrichMetadata(pf.currentPath, pf.metadataTime(), pf.Meta, pf.Correction, exiftoolPath, cfg, pf.IsImage); err != nil {
+		return fmt.Errorf("failed to rewrite metadata: %w", err)
+	}
+	fmt.Println("  └─ INFO: Metadata timestamps shifted.")
+	if err := syncFileTimestamp(pf.currentPath, pf.Time); err != nil { return fmt.Errorf("failed to sync mtime: %w", err) }
+	return nil
+}
+
+// writeShiftJournal 向日志追加一行记录。pending 记录的新路径是计划的目标路径，其他记录是文件实际所在的路径。
+func writeShiftJournal(journal *os.File, pf *plannedFile, delta time.Duration, status string, shiftErr error) error {
+	newPath := pf.currentPath
+	if status == shiftPending { newPath = pf.TargetPath }
+	entry := shiftJournalEntry{
+		ShiftedAt:    time.Now().Format(time.RFC3339),
+		Path:         pf.Path,
+		NewPath:      newPath,
+		OriginalTime: pf.Time.Add(-delta).Format(time.RFC3339Nano),
+		ShiftedTime:  pf.Time.Format(time.RFC3339Nano),
+		Delta:        describeOffset(delta),
+		Status:       status,
+	}
+	if shiftErr != nil { entry.Error = shiftErr.Error() }
+	line, err := json.Marshal(entry)
+	if err != nil { return err }
+	if _, err = journal.Write(append(line, '\n')); err != nil { return err }
+	return journal.Sync()
+}
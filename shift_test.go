@@ -0,0 +1,52 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestShiftKeepsOriginalOffsets(t *testing.T) {
+	dir := t.TempDir()
+	plus2 := time.FixedZone("", 2*60*60)
+	target := time.FixedZone("", 8*60*60)
+	utc := func(time.Time) *time.Location { return time.UTC }
+	tests := []struct {
+		name     string
+		isImage  bool
+		meta     mediaMetadata
+		original time.Time      // 规划时从元数据读到的时间
+		tagLoc   *time.Location // 规划时确定的写回时区
+		want     map[string]string
+	}{
+		{"video with Keys:CreationDate", false,
+			mediaMetadata{"CreationDate": "2024:07:01 12:00:00+02:00", "MediaCreateDate": "2024:07:01 10:00:00", "TrackCreateDate": "2024:07:01 10:00:00", "CreateDate": "2024:07:01 10:00:00"},
+			time.Date(2024, 7, 1, 12, 0, 0, 0, plus2), time.UTC,
+			map[string]string{"CreationDate": "2024:07:01 13:30:00+02:00", "MediaCreateDate": "2024:07:01 11:30:00"}},
+		{"image with OffsetTimeOriginal", true,
+			mediaMetadata{"DateTimeOriginal": "2024:07:01 12:00:00", "CreateDate": "2024:07:01 12:00:00", "ModifyDate": "2024:07:01 12:00:00", "OffsetTimeOriginal": "+02:00", "OffsetTimeDigitized": "+02:00", "OffsetTime": "+02:00"},
+			time.Date(2024, 7, 1, 12, 0, 0, 0, plus2), plus2,
+			map[string]string{"DateTimeOriginal": "2024:07:01 13:30:00", "OffsetTimeOriginal": "+02:00"}},
+	}
+	for _, tt := range tests {
+		pf := &plannedFile{
+			Path: filepath.Join(dir, "IMG_20240701_180000.jpg"), Prefix: "IMG", extension: ".jpg", IsImage: tt.isImage,
+			Time: tt.original.In(target), TagLocation: tt.tagLoc, Meta: tt.meta, Confidence: confidenceOffset,
+		}
+		delta := 90 * time.Minute
+		plan := selectShiftFiles([]*plannedFile{pf}, delta, "", "", "")
+		if len(plan) != 1 { t.Fatalf("%s: file not selected", tt.name) }
+		updated := applyTagUpdates(tt.meta, metadataUpdates(pf.metadataTime(), tt.meta, tt.isImage, pf.Correction))
+		for tag, want := range tt.want {
+			if got := updated.Get(tag); got != want { t.Errorf("%s: %s = '%s', want '%s'", tt.name, tag, got, want) }
+		}
+
+		// 再次规划：读到的是平移后的时间，不会改回原来的名字
+		at, err := getAuthoritativeTime(&plannedFile{Path: pf.TargetPath, Prefix: pf.Prefix, IsImage: tt.isImage}, updated, "exiftool", utc, nil)
+		if err != nil { t.Fatal(err) }
+		if want := tt.original.Add(delta); !at.Time.Equal(want) { t.Errorf("%s: re-planned time %v, want %v", tt.name, at.Time, want) }
+		if got, want := filepath.Base(pf.TargetPath), generateNewFilename(at.Time.In(target), pf.Prefix, pf.extension, true); got != want {
+			t.Errorf("%s: shifted to '%s', re-planned name '%s'", tt.name, got, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xmpSidecarTags 是从 XMP 旁车文件（Lightroom、darktable、digiKam 等写出）中读取的时间标签，按优先级排列。
+var xmpSidecarTags = []string{"XMP-exif:DateTimeOriginal", "XMP-photoshop:DateCreated", "XMP-xmp:CreateDate"}
+
+// sidecarTime 是从旁车文件中读到的时间。
+type sidecarTime struct {
+	value  string // 原始值；Google Takeout 的值已换算为 exiftool 格式的 UTC 时间
+	source string // 来源说明，如 "IMG_1234.jpg.xmp DateTimeOriginal"
+}
+
+// xmpSidecarPaths 返回媒体文件可能的 XMP 旁车路径：photo.jpg.xmp 和 photo.xmp（大小写两种）。
+func xmpSidecarPaths(path string) []string {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	return []string{path + ".xmp", path + ".XMP", base + ".xmp", base + ".XMP"}
+}
+
+// takeoutSidecarPaths 返回 Google Takeout 导出时与媒体文件放在一起的 JSON 文件可能的路径。
+func takeoutSidecarPaths(path string) []string {
+	return []string{path + ".json", path + ".supplemental-metadata.json", strings.TrimSuffix(path, filepath.Ext(path)) + ".json"}
+}
+
+// readSidecarTimes 按优先级返回旁车文件中的所有候选时间。XMP 旁车需要 exiftool。
+func readSidecarTimes(path, exiftoolPath string) []sidecarTime {
+	var times []sidecarTime
+	if exiftoolPath != "" {
+		for _, sidecar := range xmpSidecarPaths(path) {
+			if !pathExists(sidecar) { continue }
+			meta, err := readMetadata(sidecar, exiftoolPath, xmpSidecarTags)
+			if err != nil { continue }
+			for _, tag := range xmpSidecarTags {
+				if value := meta.Get(tag); value != "" {
+					times = append(times, sidecarTime{value: value, source: filepath.Base(sidecar) + " " + tag[strings.LastIndex(tag, ":")+1:]})
+				}
+			}
+			break
+		}
+	}
+	for _, sidecar := range takeoutSidecarPaths(path) {
+		if value, ok := readTakeoutTime(sidecar); ok {
+			times = append(times, sidecarTime{value: value, source: filepath.Base(sidecar) + " photoTakenTime"})
+			break
+		}
+	}
+	return times
+}
+
+// readTakeoutTime 读取 Google Takeout JSON 中的 photoTakenTime（Unix 秒），返回带 UTC 标记的 exiftool 格式时间。
+func readTakeoutTime(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil { return "", false }
+	var takeout struct {
+		PhotoTakenTime struct {
+			Timestamp string `json:"timestamp"`
+		} `json:"photoTakenTime"`
+	}
+	if err := json.Unmarshal(data, &takeout); err != nil { return "", false }
+	seconds, err := strconv.ParseInt(takeout.PhotoTakenTime.Timestamp, 10, 64)
+	if err != nil || seconds <= 0 { return "", false }
+	return time.Unix(seconds, 0).UTC().Format("2006:01:02 15:04:05Z07:00"), true
+}
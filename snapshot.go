@@ -0,0 +1,218 @@
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// 快照中单个文件的复制方式，记录在清单条目的 Method 字段中。
+const (
+	snapshotReflink  = "reflink"  // 写时复制克隆（Btrfs、XFS 等），瞬间完成且不占额外空间
+	snapshotHardlink = "hardlink" // 与源文件共享同一个 inode，修改源文件前必须先断开
+	snapshotCopy     = "copy"     // 普通复制，作为最后的回退方案
+)
+
+// snapshotWriter 将源目录镜像到备份目录中。每个文件依次尝试 reflink、硬链接和普通复制；
+// 某种方式在当前文件系统上不可用时，后续文件不再尝试它。
+type snapshotWriter struct {
+	reflinkUnsupported  bool
+	hardlinkUnsupported bool
+	counts              map[string]int
+}
+
+// writeSnapshot 将 sourceDir 镜像到 snapshotDir，并把每个条目写入清单。
+// 只有普通复制的文件会计算 SHA-256；reflink 和硬链接在构造上就与源文件内容一致。
+func writeSnapshot(snapshotDir, sourceDir, backupDir string, manifest *backupManifest) error {
+	if err := os.Mkdir(snapshotDir, 0755); err != nil { return fmt.Errorf("could not create snapshot directory: %w", err) }
+	absBackupDir, err := filepath.Abs(backupDir)
+	if err != nil {
+		return fmt.Errorf("could not resolve absolute path for backup directory: %w", err)
+	}
+
+	w := &snapshotWriter{counts: make(map[string]int)}
+	var dirs []manifestEntry
+	// 源目录中的硬链接在快照中同样保存为硬链接：inode -> 第一次出现的条目
+	hardlinks := make(map[fileIdentity]manifestEntry)
+	err = filepath.Walk(sourceDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil { return err }
+		if filepath.Clean(path) == filepath.Clean(absBackupDir) {
+			log.Printf("INFO: Skipping backup directory itself: %s", path)
+			return filepath.SkipDir
+		}
+		relPath, err := filepath.Rel(sourceDir, path); if err != nil { return err }
+		dest := filepath.Join(snapshotDir, relPath)
+		entry := newManifestEntry(filepath.ToSlash(relPath), info)
+
+		switch {
+		case info.IsDir():
+			if relPath != "." {
+				if err := os.Mkdir(dest, 0755); err != nil { return err }
+			}
+			// 目录的权限和时间在所有内容写完之后再设置
+			dirs = append(dirs, entry)
+		case info.Mode()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path); if err != nil { return err }
+			if err := os.Symlink(target, dest); err != nil { return err }
+			entry.LinkTarget = target
+			if uid, gid, ok := fileOwnerOf(info); ok { chownIfPermitted(dest, uid, gid) }
+		case info.Mode().IsRegular():
+			id, nlink, ok := fileIdentityOf(info)
+			if first, seen := hardlinks[id]; ok && nlink > 1 && seen {
+				if err := os.Link(filepath.Join(snapshotDir, filepath.FromSlash(first.Path)), dest); err != nil { return err }
+				entry.Method, entry.HardlinkTo = first.Method, first.Path
+				break
+			}
+			method, sum, err := w.mirrorFile(path, dest, info)
+			if err != nil { return fmt.Errorf("could not snapshot '%s': %w", relPath, err) }
+			entry.Method, entry.SHA256 = method, sum
+			w.counts[method]++
+			if ok && nlink > 1 { hardlinks[id] = entry }
+		default:
+			log.Printf("WARNING: Skipping special file '%s' in snapshot.", relPath)
+			return nil
+		}
+		manifest.Entries = append(manifest.Entries, entry)
+		return nil
+	})
+	if err != nil { return err }
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		dest := filepath.Join(snapshotDir, filepath.FromSlash(dirs[i].Path))
+		src := filepath.Join(sourceDir, filepath.FromSlash(dirs[i].Path))
+		info, err := os.Lstat(src); if err != nil { return err }
+		if err := preserveFileAttrs(src, dest, info); err != nil { return err }
+	}
+	fmt.Printf("Snapshot: %d reflinked, %d hardlinked, %d copied.\n", w.counts[snapshotReflink], w.counts[snapshotHardlink], w.counts[snapshotCopy])
+	return nil
+}
+
+// snapshotSize 估算快照目录实际占用的空间：普通复制的文件计入全部大小；reflink 的文件与源文件共享数据块，不计入；
+// 硬链接的文件只有在与源文件的链接已经断开（源文件被修改过）、inode 只剩快照中的链接时才计入。
+func snapshotSize(snapshotDir string, m *backupManifest) int64 {
+	// 源目录中的硬链接在快照中也互为硬链接，它们不算与源文件共享
+	internal := make(map[string]uint64)
+	for _, e := range m.Entries {
+		if e.HardlinkTo != "" { internal[e.HardlinkTo]++ }
+	}
+	var size int64
+	for _, e := range m.Entries {
+		if e.HardlinkTo != "" { continue }
+		switch e.Method {
+		case snapshotCopy:
+			size += e.Size
+		case snapshotHardlink:
+			info, err := os.Lstat(filepath.Join(snapshotDir, filepath.FromSlash(e.Path)))
+			if err != nil { continue }
+			if _, nlink, ok := fileIdentityOf(info); ok && nlink <= 1+internal[e.Path] { size += info.Size() }
+		}
+	}
+	return size
+}
+
+// mirrorFile 用可用的最快方式把一个普通文件放进快照，返回所用的方式以及（仅复制时的）SHA-256。
+func (w *snapshotWriter) mirrorFile(src, dest string, info fs.FileInfo) (string, string, error) {
+	if !w.reflinkUnsupported {
+		err := reflinkFile(src, dest)
+		if err == nil { return snapshotReflink, "", preserveFileAttrs(src, dest, info) }
+		if !isUnsupportedLinkError(err) { return "", "", err }
+		w.reflinkUnsupported = true
+	}
+	if !w.hardlinkUnsupported {
+		err := os.Link(src, dest)
+		if err == nil { return snapshotHardlink, "", nil }
+		if !isUnsupportedLinkError(err) { return "", "", err }
+		w.hardlinkUnsupported = true
+	}
+	sum, err := copyFileWithHash(src, dest, info)
+	return snapshotCopy, sum, err
+}
+
+// isUnsupportedLinkError 判断错误是否表示“当前文件系统不支持这种方式”，而不是真正的 I/O 错误。
+func isUnsupportedLinkError(err error) bool {
+	return errors.Is(err, errReflinkUnsupported) ||
+		errors.Is(err, syscall.EXDEV) || errors.Is(err, syscall.EPERM) ||
+		errors.Is(err, syscall.EOPNOTSUPP) || errors.Is(err, syscall.ENOTSUP) ||
+		errors.Is(err, syscall.EINVAL) || errors.Is(err, syscall.ENOTTY) ||
+		errors.Is(err, syscall.EMLINK) || errors.Is(err, os.ErrPermission)
+}
+
+// copyFileWithHash 复制文件内容并同时计算 SHA-256，然后还原属主、扩展属性、权限和修改时间。
+func copyFileWithHash(src, dest string, info fs.FileInfo) (string, error) {
+	in, err := os.Open(src)
+	if err != nil { return "", err }
+	defer in.Close()
+	out, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil { return "", err }
+
+	hasher := sha256.New()
+	n, err := io.Copy(io.MultiWriter(out, hasher), in)
+	if err == nil { err = out.Sync() }
+	if closeErr := out.Close(); err == nil { err = closeErr }
+	if err != nil { return "", err }
+	if n != info.Size() { return "", fmt.Errorf("file changed size while being copied") }
+	return hex.EncodeToString(hasher.Sum(nil)), preserveFileAttrs(src, dest, info)
+}
+
+// preserveFileAttrs 把 src 的属主、扩展属性、权限和修改时间复制到 dest。
+// 属主必须最先设置，因为 chown 会清除 setuid/setgid 位。
+func preserveFileAttrs(src, dest string, info fs.FileInfo) error {
+	if uid, gid, ok := fileOwnerOf(info); ok { chownIfPermitted(dest, uid, gid) }
+	xattrs, err := readXattrs(src)
+	if err != nil { return err }
+	if err := writeXattrs(dest, xattrs); err != nil { log.Printf("WARNING: Could not copy extended attributes to '%s': %v", dest, err) }
+	if err := os.Chmod(dest, info.Mode().Perm()); err != nil { return err }
+	return os.Chtimes(dest, info.ModTime(), info.ModTime())
+}
+
+// walkSnapshot 以与 walkArchive 相同的形式遍历一个快照目录，使校验和还原逻辑可以共用。
+// 快照内部共享 inode 的文件与归档中一样表示为 TypeLink 条目。
+func walkSnapshot(snapshotDir string, fn func(hdr *tar.Header, relPath string, r io.Reader) error) error {
+	hardlinks := make(map[fileIdentity]string)
+	return filepath.Walk(snapshotDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil { return err }
+		relPath, err := filepath.Rel(snapshotDir, path); if err != nil { return err }
+		hdr, err := fileHeader(path, info); if err != nil { return err }
+		hdr.Name = filepath.ToSlash(relPath)
+		if !info.Mode().IsRegular() { return fn(hdr, relPath, eofReader{}) }
+		if id, nlink, ok := fileIdentityOf(info); ok && nlink > 1 {
+			if first, seen := hardlinks[id]; seen {
+				hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, first, 0
+				return fn(hdr, relPath, eofReader{})
+			}
+			hardlinks[id] = hdr.Name
+		}
+
+		f, err := os.Open(path); if err != nil { return err }
+		defer f.Close()
+		return fn(hdr, relPath, f)
+	})
+}
+
+type eofReader struct{}
+
+func (eofReader) Read([]byte) (int, error) { return 0, io.EOF }
+
+// breakHardlink 实现硬链接快照的“修改前复制”：在就地修改文件之前，把它替换为一份独立的副本，
+// 使快照中共享同一个 inode 的那份保持不变。
+func breakHardlink(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil { return err }
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".media-sorter-cow-*")
+	if err != nil { return err }
+	tmpPath := tmp.Name()
+	tmp.Close()
+	os.Remove(tmpPath)
+
+	if _, err := copyFileWithHash(path, tmpPath, info); err != nil { os.Remove(tmpPath); return err }
+	if err := os.Rename(tmpPath, path); err != nil { os.Remove(tmpPath); return err }
+	return nil
+}
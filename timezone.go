@@ -0,0 +1,178 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"media-sorter/tzmap"
+)
+
+// 文件名使用的时区
+const (
+	filenameTimezoneTarget = "target" // 统一换算到 target_timezone
+	filenameTimezoneLocal  = "local"  // 使用拍摄地的当地时间
+)
+
+// gpsTags 是用于确定拍摄地时区的坐标标签。'#' 让 exiftool 输出带符号的十进制数值，而不是度分秒字符串。
+var gpsTags = []string{"Composite:GPSLatitude#", "Composite:GPSLongitude#"}
+
+// itineraryEntry 是配置文件中行程的一段：from 到 to 之间（含两端，均为当地日期或时间）在 timezone 所在的地方。
+// make 和 model 不为空时只匹配这台相机拍摄的文件（不区分大小写）。
+type itineraryEntry struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Timezone string `json:"timezone"`
+	Make     string `json:"make"`
+	Model    string `json:"model"`
+}
+
+// itineraryStop 是解析后的行程。from 和 to 以 UTC 表示当地的钟面时间，to 不含。
+type itineraryStop struct {
+	from, to    time.Time
+	location    *time.Location
+	name        string
+	make, model string
+}
+
+// wallTimeLayouts 是行程和时钟修正中 from 和 to 可以使用的格式。只有日期时，to 包含当天全天。
+var wallTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"}
+
+// timeZones 决定每个文件的时间应当按哪个时区解释，以及文件名应当使用哪个时区。
+type timeZones struct {
+	target     *time.Location
+	gpsMap     *tzmap.Map // 为 nil 时不根据 GPS 坐标解析时区
+	coarseMap  bool       // gpsMap 是内嵌的粗略数据：边境附近可能出错，匹配的行程优先于它
+	itinerary  []itineraryStop
+	videoRules videoTimeRules // 不带时区的视频时间是 UTC 还是当地时间
+	localNames bool // 文件名是否使用拍摄地的当地时间
+	cache      map[string]*time.Location
+}
+
+// newTimeZones 根据配置构造时区解析器。启用 GPS 时区时会加载边界数据：
+// 配置了 timezone_boundaries_file 时使用该文件，否则使用内嵌的粗略数据。
+func newTimeZones(cfg Config, target *time.Location) (*timeZones, error) {
+	z := &timeZones{target: target, localNames: cfg.FilenameTimezone == filenameTimezoneLocal, cache: make(map[string]*time.Location)}
+	var err error
+	if z.videoRules, err = parseVideoTimeRules(cfg.VideoTimeConventions); err != nil { return nil, err }
+	for i, entry := range cfg.Itinerary {
+		stop, err := parseItineraryEntry(entry)
+		if err != nil { return nil, fmt.Errorf("invalid itinerary entry %d: %w", i+1, err) }
+		z.itinerary = append(z.itinerary, stop)
+	}
+	if !cfg.TimezoneFromGPS { return z, nil }
+
+	if cfg.TimezoneBoundariesFile != "" {
+		z.gpsMap, err = tzmap.Load(cfg.TimezoneBoundariesFile)
+	} else {
+		z.gpsMap, err = tzmap.Embedded()
+		z.coarseMap = true
+	}
+	if err != nil { return nil, fmt.Errorf("could not load timezone boundaries: %w", err) }
+	return z, nil
+}
+
+// captureZone 返回文件拍摄地的时区及其说明。无法确定时返回 false，调用者应使用目标时区。
+func (z *timeZones) captureZone(meta mediaMetadata) (*time.Location, string, bool) {
+	if z.gpsMap == nil { return nil, "", false }
+	lat, errLat := parseGPSCoordinate(meta.Get("GPSLatitude"))
+	lon, errLon := parseGPSCoordinate(meta.Get("GPSLongitude"))
+	// 0,0 几乎总是设备写入的占位值，而不是真实位置
+	if errLat != nil || errLon != nil || (lat == 0 && lon == 0) { return nil, "", false }
+	if math.Abs(lat) > 90 || math.Abs(lon) > 180 { return nil, "", false }
+
+	name, found := z.gpsMap.Lookup(lat, lon)
+	description := fmt.Sprintf("%s (from GPS %.4f, %.4f)", name, lat, lon)
+	if z.coarseMap { description = fmt.Sprintf("%s (from GPS %.4f, %.4f, coarse built-in map)", name, lat, lon) }
+	if !found {
+		name = tzmap.Nautical(lon)
+		description = fmt.Sprintf("%s (nautical zone from GPS %.4f, %.4f)", name, lat, lon)
+	}
+	loc, err := z.load(name)
+	if err != nil { return nil, "", false }
+	return loc, description, true
+}
+
+// itineraryZone 返回文件拍摄时所在行程的时区及其说明。naive 为 true 时 t 是以 UTC 表示的钟面读数，
+// 否则 t 是一个确定的时刻，会换算到各段行程的时区后再比较。多段行程重叠时，排在前面的优先。
+func (z *timeZones) itineraryZone(meta mediaMetadata, t time.Time, naive bool) (*time.Location, string, bool) {
+	cameraMake, cameraModel := meta.Get("Make"), meta.Get("Model")
+	for _, stop := range z.itinerary {
+		if stop.make != "" && !strings.EqualFold(stop.make, cameraMake) { continue }
+		if stop.model != "" && !strings.EqualFold(stop.model, cameraModel) { continue }
+		wall := t
+		if !naive { wall = wallClock(t.In(stop.location)) }
+		if wall.Before(stop.from) || !wall.Before(stop.to) { continue }
+		return stop.location, fmt.Sprintf("%s (from itinerary %s)", stop.name, stop.from.Format("2006-01-02")), true
+	}
+	return nil, "", false
+}
+
+func parseItineraryEntry(entry itineraryEntry) (itineraryStop, error) {
+	stop := itineraryStop{name: entry.Timezone, make: strings.TrimSpace(entry.Make), model: strings.TrimSpace(entry.Model)}
+	var err error
+	if stop.location, err = parseTimeZone(entry.Timezone); err != nil { return stop, err }
+	if stop.from, _, err = parseWallTime(entry.From); err != nil { return stop, fmt.Errorf("from: %w", err) }
+	var dateOnly bool
+	if stop.to, dateOnly, err = parseWallTime(entry.To); err != nil { return stop, fmt.Errorf("to: %w", err) }
+	if dateOnly {
+		stop.to = stop.to.AddDate(0, 0, 1)
+	} else {
+		stop.to = stop.to.Add(time.Second)
+	}
+	if !stop.from.Before(stop.to) { return stop, fmt.Errorf("'from' (%s) is after 'to' (%s)", entry.From, entry.To) }
+	return stop, nil
+}
+
+// parseWallTime 把配置中的日期或时间（行程、时钟修正）解析为以 UTC 表示的钟面时间，并返回它是否只有日期。
+func parseWallTime(s string) (time.Time, bool, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range wallTimeLayouts {
+		if t, err := time.Parse(layout, s); err == nil { return t, !strings.Contains(layout, "15"), nil }
+	}
+	return time.Time{}, false, fmt.Errorf("could not parse '%s', expected YYYY-MM-DD or YYYY-MM-DD HH:MM[:SS]", s)
+}
+
+// wallClock 返回 t 在其自身时区中的钟面时间，以 UTC 表示。
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// filenameLocation 返回文件名应当使用的时区。
+// 使用当地时间时，优先采用标签自带的时区偏移，其次是拍摄地时区，都没有时退回目标时区。
+func (z *timeZones) filenameLocation(at authoritativeTime, capture *time.Location) *time.Location {
+	if !z.localNames { return z.target }
+	if at.HasOffset { return at.Time.Location() }
+	if capture != nil { return capture }
+	return z.target
+}
+
+func (z *timeZones) load(name string) (*time.Location, error) {
+	if loc, ok := z.cache[name]; ok { return loc, nil }
+	loc, err := time.LoadLocation(name)
+	if err != nil { return nil, err }
+	z.cache[name] = loc
+	return loc, nil
+}
+
+// gpsDMSPattern 匹配 exiftool 默认的度分秒格式，如 `48 deg 51' 24.00" N`。
+var gpsDMSPattern = regexp.MustCompile(`^(\d+(?:\.\d+)?) deg (\d+(?:\.\d+)?)' (\d+(?:\.\d+)?)"\s*([NSEW])?$`)
+
+// parseGPSCoordinate 解析十进制（可带符号）或度分秒格式的坐标。
+func parseGPSCoordinate(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	if s == "" { return 0, fmt.Errorf("empty coordinate") }
+	if v, err := strconv.ParseFloat(s, 64); err == nil { return v, nil }
+
+	m := gpsDMSPattern.FindStringSubmatch(s)
+	if m == nil { return 0, fmt.Errorf("could not parse coordinate: %s", s) }
+	deg, _ := strconv.ParseFloat(m[1], 64)
+	min, _ := strconv.ParseFloat(m[2], 64)
+	sec, _ := strconv.ParseFloat(m[3], 64)
+	v := deg + min/60 + sec/3600
+	if m[4] == "S" || m[4] == "W" { v = -v }
+	return v, nil
+}
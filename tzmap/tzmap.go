@@ -0,0 +1,147 @@
+// Package tzmap 根据经纬度离线查找 IANA 时区。
+//
+// 程序内嵌了一份粗略的时区边界数据（boundaries.geojson），由按国家和地区手工划定、互不重叠的经纬度矩形组成，
+// 精度大致到国家或省份一级，边境附近几十公里内可能出错（例如紧邻香港的深圳），只适合作为没有更可靠来源时的后备。
+// 需要精确边界时，可以加载
+// timezone-boundary-builder 发布的完整 GeoJSON 数据（combined.json 或 combined-with-oceans.json）。
+package tzmap
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+
+	// 内嵌 IANA 时区数据库，使查到的时区在没有系统时区数据的环境（如 Windows）中也能加载
+	_ "time/tzdata"
+)
+
+//go:embed boundaries.geojson
+var embeddedBoundaries []byte
+
+// point 是 GeoJSON 中的一个坐标，顺序为 [经度, 纬度]。
+type point [2]float64
+
+// zone 是一个时区及其边界。每个多边形由若干个环组成，第一个是外边界，其余是洞。
+type zone struct {
+	name     string
+	polygons [][][]point
+	minLon, minLat, maxLon, maxLat float64
+}
+
+// Map 是一份可查询的时区边界数据。
+type Map struct {
+	zones []zone
+}
+
+// Embedded 返回内嵌的粗略时区边界数据。
+func Embedded() (*Map, error) {
+	return Parse(embeddedBoundaries)
+}
+
+// Load 从文件加载 GeoJSON 格式的时区边界数据。
+func Load(path string) (*Map, error) {
+	data, err := os.ReadFile(path)
+	if err != nil { return nil, err }
+	m, err := Parse(data)
+	if err != nil { return nil, fmt.Errorf("%s: %w", path, err) }
+	return m, nil
+}
+
+// Parse 解析 GeoJSON FeatureCollection。每个 Feature 的 properties.tzid 是时区名称，
+// geometry 可以是 Polygon 或 MultiPolygon。边界重叠时，排在前面的 Feature 优先。
+func Parse(data []byte) (*Map, error) {
+	var collection struct {
+		Features []struct {
+			Properties struct {
+				TZID string `json:"tzid"`
+			} `json:"properties"`
+			Geometry struct {
+				Type        string          `json:"type"`
+				Coordinates json.RawMessage `json:"coordinates"`
+			} `json:"geometry"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(data, &collection); err != nil { return nil, fmt.Errorf("invalid timezone boundary data: %w", err) }
+
+	m := &Map{}
+	for i, f := range collection.Features {
+		if f.Properties.TZID == "" { return nil, fmt.Errorf("feature %d has no 'tzid' property", i) }
+		z := zone{name: f.Properties.TZID, minLon: math.Inf(1), minLat: math.Inf(1), maxLon: math.Inf(-1), maxLat: math.Inf(-1)}
+		var err error
+		switch f.Geometry.Type {
+		case "Polygon":
+			var polygon [][]point
+			err = json.Unmarshal(f.Geometry.Coordinates, &polygon)
+			z.polygons = [][][]point{polygon}
+		case "MultiPolygon":
+			err = json.Unmarshal(f.Geometry.Coordinates, &z.polygons)
+		default:
+			return nil, fmt.Errorf("feature %d (%s) has unsupported geometry type %q", i, z.name, f.Geometry.Type)
+		}
+		if err != nil { return nil, fmt.Errorf("feature %d (%s): %w", i, z.name, err) }
+		for _, polygon := range z.polygons {
+			if len(polygon) == 0 { continue }
+			for _, p := range polygon[0] {
+				z.minLon, z.maxLon = math.Min(z.minLon, p[0]), math.Max(z.maxLon, p[0])
+				z.minLat, z.maxLat = math.Min(z.minLat, p[1]), math.Max(z.maxLat, p[1])
+			}
+		}
+		m.zones = append(m.zones, z)
+	}
+	if len(m.zones) == 0 { return nil, fmt.Errorf("timezone boundary data contains no features") }
+	return m, nil
+}
+
+// Lookup 返回坐标所在的 IANA 时区名称。坐标不在任何边界内时返回 false。
+func (m *Map) Lookup(lat, lon float64) (string, bool) {
+	for _, z := range m.zones {
+		if lon < z.minLon || lon > z.maxLon || lat < z.minLat || lat > z.maxLat { continue }
+		for _, polygon := range z.polygons {
+			if containsPoint(polygon, lon, lat) { return z.name, true }
+		}
+	}
+	return "", false
+}
+
+// Nautical 返回按经度划分的航海时区（每 15 度一小时），用于海上或边界数据未覆盖的位置。
+// 注意 Etc/GMT 时区名称中的符号与 UTC 偏移相反：东八区是 "Etc/GMT-8"。
+func Nautical(lon float64) string {
+	hours := int(math.Round(lon / 15))
+	switch {
+	case hours == 0:
+		return "Etc/GMT"
+	case hours > 0:
+		return fmt.Sprintf("Etc/GMT-%d", hours)
+	default:
+		return fmt.Sprintf("Etc/GMT+%d", -hours)
+	}
+}
+
+// containsPoint 用射线法判断点是否在多边形内：位于外边界之内、且不在任何一个洞中。
+func containsPoint(polygon [][]point, x, y float64) bool {
+	if len(polygon) == 0 || !ringContains(polygon[0], x, y) { return false }
+	for _, hole := range polygon[1:] {
+		if ringContains(hole, x, y) { return false }
+	}
+	return true
+}
+
+// ringContains 判断点是否在一个闭合环内。落在矩形边界上的点（内嵌数据的常见情况）视为在内。
+func ringContains(ring []point, x, y float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i][0], ring[i][1]
+		xj, yj := ring[j][0], ring[j][1]
+		if onSegment(xi, yi, xj, yj, x, y) { return true }
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi { inside = !inside }
+	}
+	return inside
+}
+
+func onSegment(x1, y1, x2, y2, x, y float64) bool {
+	cross := (x2-x1)*(y-y1) - (y2-y1)*(x-x1)
+	if math.Abs(cross) > 1e-12 { return false }
+	return x >= math.Min(x1, x2) && x <= math.Max(x1, x2) && y >= math.Min(y1, y2) && y <= math.Max(y1, y2)
+}
@@ -0,0 +1,214 @@
+package tzmap
+
+import (
+	"testing"
+)
+
+func TestEmbeddedLookup(t *testing.T) {
+	m, err := Embedded()
+	if err != nil { t.Fatal(err) }
+	tests := []struct {
+		city     string
+		lat, lon float64
+		want     string
+	}{
+		// 边境附近的城市
+		{"Kolkata", 22.57, 88.36, "Asia/Kolkata"},
+		{"Guwahati", 26.14, 91.74, "Asia/Kolkata"},
+		{"Siliguri", 26.73, 88.40, "Asia/Kolkata"},
+		{"Agartala", 23.83, 91.28, "Asia/Kolkata"},
+		{"Imphal", 24.82, 93.94, "Asia/Kolkata"},
+		{"Dhaka", 23.81, 90.41, "Asia/Dhaka"},
+		{"Chittagong", 22.36, 91.78, "Asia/Dhaka"},
+		{"Thimphu", 27.47, 89.64, "Asia/Thimphu"},
+		{"Kathmandu", 27.72, 85.32, "Asia/Kathmandu"},
+		{"Lhasa", 29.65, 91.17, "Asia/Shanghai"},
+		{"Mandalay", 21.97, 96.08, "Asia/Yangon"},
+		{"Lahore", 31.55, 74.34, "Asia/Karachi"},
+		{"Lviv", 49.84, 24.03, "Europe/Kyiv"},
+		{"Chernivtsi", 48.29, 25.94, "Europe/Kyiv"},
+		{"Odesa", 46.48, 30.72, "Europe/Kyiv"},
+		{"Warsaw", 52.23, 21.01, "Europe/Warsaw"},
+		{"Lublin", 51.25, 22.57, "Europe/Warsaw"},
+		{"Bialystok", 53.13, 23.16, "Europe/Warsaw"},
+		{"Szczecin", 53.43, 14.55, "Europe/Warsaw"},
+		{"Opole", 50.67, 17.93, "Europe/Warsaw"},
+		{"Vilnius", 54.69, 25.28, "Europe/Vilnius"},
+		{"Kaunas", 54.90, 23.90, "Europe/Vilnius"},
+		{"Minsk", 53.90, 27.56, "Europe/Minsk"},
+		{"Brest", 52.10, 23.70, "Europe/Minsk"},
+		{"Grodno", 53.68, 23.83, "Europe/Minsk"},
+		{"Vitebsk", 55.19, 30.20, "Europe/Minsk"},
+		{"Daugavpils", 55.87, 26.53, "Europe/Riga"},
+		{"Smolensk", 54.78, 32.05, "Europe/Moscow"},
+		{"Chisinau", 47.01, 28.86, "Europe/Chisinau"},
+		{"Iasi", 47.16, 27.59, "Europe/Bucharest"},
+		{"Constanta", 44.17, 28.63, "Europe/Bucharest"},
+		{"Timisoara", 45.76, 21.23, "Europe/Bucharest"},
+		{"Bratislava", 48.15, 17.11, "Europe/Bratislava"},
+		{"Munich", 48.14, 11.58, "Europe/Berlin"},
+		{"Geneva", 46.20, 6.14, "Europe/Zurich"},
+		{"Strasbourg", 48.57, 7.75, "Europe/Paris"},
+		{"Barcelona", 41.39, 2.17, "Europe/Madrid"},
+		{"Malmo", 55.60, 13.00, "Europe/Stockholm"},
+		{"Boise", 43.62, -116.20, "America/Boise"},
+		{"Twin Falls", 42.56, -114.46, "America/Boise"},
+		{"Ontario, Oregon", 44.03, -116.96, "America/Boise"},
+		{"Coeur d'Alene", 47.68, -116.78, "America/Los_Angeles"},
+		{"Spokane", 47.66, -117.43, "America/Los_Angeles"},
+		{"Reno", 39.53, -119.81, "America/Los_Angeles"},
+		{"Las Vegas", 36.17, -115.14, "America/Los_Angeles"},
+		{"Salt Lake City", 40.76, -111.89, "America/Denver"},
+		{"San Diego", 32.72, -117.16, "America/Los_Angeles"},
+		{"Tijuana", 32.51, -117.04, "America/Tijuana"},
+		{"Broken Hill", -31.95, 141.45, "Australia/Broken_Hill"},
+		{"Mildura", -34.21, 142.14, "Australia/Melbourne"},
+		{"Canberra", -35.28, 149.13, "Australia/Sydney"},
+		{"Gold Coast", -28.02, 153.40, "Australia/Brisbane"},
+		{"Harbin", 45.80, 126.53, "Asia/Shanghai"},
+		{"Khabarovsk", 48.48, 135.07, "Asia/Vladivostok"},
+		{"Macau", 22.20, 113.54, "Asia/Macau"},
+		{"Johor Bahru", 1.49, 103.74, "Asia/Kuala_Lumpur"},
+		// 各时区的主要城市
+		{"Sydney", -33.87, 151.21, "Australia/Sydney"},
+		{"Adelaide", -34.93, 138.60, "Australia/Adelaide"},
+		{"Melbourne", -37.81, 144.96, "Australia/Melbourne"},
+		{"Brisbane", -27.47, 153.03, "Australia/Brisbane"},
+		{"Perth", -31.95, 115.86, "Australia/Perth"},
+		{"Darwin", -12.46, 130.84, "Australia/Darwin"},
+		{"Hobart", -42.88, 147.33, "Australia/Hobart"},
+		{"Auckland", -36.85, 174.76, "Pacific/Auckland"},
+		{"Los Angeles", 34.05, -118.24, "America/Los_Angeles"},
+		{"Seattle", 47.61, -122.33, "America/Los_Angeles"},
+		{"Denver", 39.74, -104.99, "America/Denver"},
+		{"Phoenix", 33.45, -112.07, "America/Phoenix"},
+		{"Chicago", 41.88, -87.63, "America/Chicago"},
+		{"Indianapolis", 39.77, -86.16, "America/Indiana/Indianapolis"},
+		{"Detroit", 42.33, -83.05, "America/Detroit"},
+		{"New York", 40.71, -74.01, "America/New_York"},
+		{"Miami", 25.76, -80.19, "America/New_York"},
+		{"Toronto", 43.65, -79.38, "America/Toronto"},
+		{"Montreal", 45.50, -73.57, "America/Toronto"},
+		{"Vancouver", 49.28, -123.12, "America/Vancouver"},
+		{"Calgary", 51.05, -114.07, "America/Edmonton"},
+		{"Regina", 50.45, -104.62, "America/Regina"},
+		{"Winnipeg", 49.90, -97.14, "America/Winnipeg"},
+		{"Halifax", 44.65, -63.57, "America/Halifax"},
+		{"St. John's", 47.56, -52.71, "America/St_Johns"},
+		{"Anchorage", 61.22, -149.90, "America/Anchorage"},
+		{"Honolulu", 21.31, -157.86, "Pacific/Honolulu"},
+		{"Mexico City", 19.43, -99.13, "America/Mexico_City"},
+		{"Havana", 23.11, -82.37, "America/Havana"},
+		{"Bogota", 4.71, -74.07, "America/Bogota"},
+		{"Lima", -12.05, -77.04, "America/Lima"},
+		{"Santiago", -33.45, -70.67, "America/Santiago"},
+		{"Buenos Aires", -34.60, -58.38, "America/Argentina/Buenos_Aires"},
+		{"Montevideo", -34.90, -56.16, "America/Montevideo"},
+		{"Sao Paulo", -23.55, -46.63, "America/Sao_Paulo"},
+		{"Manaus", -3.12, -60.02, "America/Manaus"},
+		{"Caracas", 10.48, -66.90, "America/Caracas"},
+		{"London", 51.51, -0.13, "Europe/London"},
+		{"Dublin", 53.35, -6.26, "Europe/Dublin"},
+		{"Lisbon", 38.72, -9.14, "Europe/Lisbon"},
+		{"Madrid", 40.42, -3.70, "Europe/Madrid"},
+		{"Paris", 48.86, 2.35, "Europe/Paris"},
+		{"Brussels", 50.85, 4.35, "Europe/Brussels"},
+		{"Amsterdam", 52.37, 4.90, "Europe/Amsterdam"},
+		{"Luxembourg", 49.61, 6.13, "Europe/Luxembourg"},
+		{"Zurich", 47.38, 8.54, "Europe/Zurich"},
+		{"Berlin", 52.52, 13.40, "Europe/Berlin"},
+		{"Prague", 50.08, 14.44, "Europe/Prague"},
+		{"Vienna", 48.21, 16.37, "Europe/Vienna"},
+		{"Budapest", 47.50, 19.04, "Europe/Budapest"},
+		{"Rome", 41.90, 12.50, "Europe/Rome"},
+		{"Milan", 45.46, 9.19, "Europe/Rome"},
+		{"Ljubljana", 46.06, 14.51, "Europe/Ljubljana"},
+		{"Zagreb", 45.81, 15.98, "Europe/Zagreb"},
+		{"Belgrade", 44.79, 20.45, "Europe/Belgrade"},
+		{"Sofia", 42.70, 23.32, "Europe/Sofia"},
+		{"Bucharest", 44.43, 26.10, "Europe/Bucharest"},
+		{"Athens", 37.98, 23.73, "Europe/Athens"},
+		{"Istanbul", 41.01, 28.98, "Europe/Istanbul"},
+		{"Copenhagen", 55.68, 12.57, "Europe/Copenhagen"},
+		{"Oslo", 59.91, 10.75, "Europe/Oslo"},
+		{"Stockholm", 59.33, 18.07, "Europe/Stockholm"},
+		{"Helsinki", 60.17, 24.94, "Europe/Helsinki"},
+		{"Tallinn", 59.44, 24.75, "Europe/Tallinn"},
+		{"Riga", 56.95, 24.11, "Europe/Riga"},
+		{"Kaliningrad", 54.71, 20.51, "Europe/Kaliningrad"},
+		{"Kyiv", 50.45, 30.52, "Europe/Kyiv"},
+		{"Moscow", 55.76, 37.62, "Europe/Moscow"},
+		{"Yekaterinburg", 56.84, 60.61, "Asia/Yekaterinburg"},
+		{"Novosibirsk", 55.01, 82.93, "Asia/Novosibirsk"},
+		{"Irkutsk", 52.29, 104.28, "Asia/Irkutsk"},
+		{"Vladivostok", 43.12, 131.89, "Asia/Vladivostok"},
+		{"Cairo", 30.04, 31.24, "Africa/Cairo"},
+		{"Jerusalem", 31.77, 35.21, "Asia/Jerusalem"},
+		{"Amman", 31.95, 35.93, "Asia/Amman"},
+		{"Beirut", 33.89, 35.50, "Asia/Beirut"},
+		{"Damascus", 33.51, 36.28, "Asia/Damascus"},
+		{"Baghdad", 33.31, 44.36, "Asia/Baghdad"},
+		{"Riyadh", 24.71, 46.68, "Asia/Riyadh"},
+		{"Dubai", 25.20, 55.27, "Asia/Dubai"},
+		{"Tehran", 35.69, 51.39, "Asia/Tehran"},
+		{"Kabul", 34.56, 69.21, "Asia/Kabul"},
+		{"Karachi", 24.86, 67.00, "Asia/Karachi"},
+		{"Tashkent", 41.30, 69.24, "Asia/Tashkent"},
+		{"Almaty", 43.24, 76.95, "Asia/Almaty"},
+		{"Delhi", 28.61, 77.21, "Asia/Kolkata"},
+		{"Mumbai", 19.08, 72.88, "Asia/Kolkata"},
+		{"Colombo", 6.93, 79.86, "Asia/Colombo"},
+		{"Yangon", 16.87, 96.20, "Asia/Yangon"},
+		{"Bangkok", 13.76, 100.50, "Asia/Bangkok"},
+		{"Ho Chi Minh City", 10.82, 106.63, "Asia/Ho_Chi_Minh"},
+		{"Kuala Lumpur", 3.14, 101.69, "Asia/Kuala_Lumpur"},
+		{"Singapore", 1.35, 103.82, "Asia/Singapore"},
+		{"Jakarta", -6.20, 106.85, "Asia/Jakarta"},
+		{"Manila", 14.60, 120.98, "Asia/Manila"},
+		{"Hong Kong", 22.32, 114.17, "Asia/Hong_Kong"},
+		{"Sheung Shui", 22.50, 114.13, "Asia/Hong_Kong"},
+		{"Shenzhen", 22.54, 114.06, "Asia/Shanghai"},
+		{"Macau", 22.19, 113.54, "Asia/Macau"},
+		{"Zhuhai", 22.27, 113.57, "Asia/Shanghai"},
+		{"Shanghai", 31.23, 121.47, "Asia/Shanghai"},
+		{"Beijing", 39.90, 116.41, "Asia/Shanghai"},
+		{"Taipei", 25.03, 121.56, "Asia/Taipei"},
+		{"Seoul", 37.57, 126.98, "Asia/Seoul"},
+		{"Busan", 35.18, 129.08, "Asia/Seoul"},
+		{"Pyongyang", 39.04, 125.76, "Asia/Pyongyang"},
+		{"Tokyo", 35.68, 139.69, "Asia/Tokyo"},
+		{"Fukuoka", 33.59, 130.40, "Asia/Tokyo"},
+		{"Ulaanbaatar", 47.89, 106.91, "Asia/Ulaanbaatar"},
+		{"Johannesburg", -26.20, 28.05, "Africa/Johannesburg"},
+		{"Nairobi", -1.29, 36.82, "Africa/Nairobi"},
+		{"Lagos", 6.52, 3.38, "Africa/Lagos"},
+		{"Casablanca", 33.57, -7.59, "Africa/Casablanca"},
+		{"Algiers", 36.75, 3.06, "Africa/Algiers"},
+		{"Tunis", 36.81, 10.18, "Africa/Tunis"},
+		{"Addis Ababa", 9.03, 38.74, "Africa/Addis_Ababa"},
+		{"Kinshasa", -4.44, 15.27, "Africa/Kinshasa"},
+	}
+	for _, tt := range tests {
+		got, ok := m.Lookup(tt.lat, tt.lon)
+		if !ok || got != tt.want { t.Errorf("%s (%v, %v): got %q, want %q", tt.city, tt.lat, tt.lon, got, tt.want) }
+	}
+}
+
+// TestEmbeddedNoOverlap 检查内嵌数据中的时区互不重叠：查找结果不能取决于 Feature 的顺序。
+func TestEmbeddedNoOverlap(t *testing.T) {
+	m, err := Embedded()
+	if err != nil { t.Fatal(err) }
+	// 网格点的坐标不会落在边界数据使用的两位小数上，避免把共用的边界算作重叠
+	for lat := -89.8766; lat < 90; lat += 0.5 {
+		for lon := -179.8766; lon < 180; lon += 0.5 {
+			var found []string
+			for _, z := range m.zones {
+				if lon < z.minLon || lon > z.maxLon || lat < z.minLat || lat > z.maxLat { continue }
+				for _, polygon := range z.polygons {
+					if containsPoint(polygon, lon, lat) { found = append(found, z.name) }
+				}
+			}
+			if len(found) > 1 { t.Errorf("(%.4f, %.4f) is inside %v", lat, lon, found) }
+		}
+	}
+}
@@ -17,6 +17,10 @@ Intelligently organizes media files in a specified directory with a confirmation
 
 Usage:
   media-sorter -dir <TARGET_DIRECTORY> [options]
+  media-sorter restore [options] [ARCHIVE] [TARGET_DIRECTORY]
+  media-sorter backup list|prune [options]
+  media-sorter shift (-by DURATION | -ref FILE -to TIME) [options] TARGET_DIRECTORY
+  media-sorter review [options] TARGET_DIRECTORY
 
 Arguments:
   TARGET_DIRECTORY  The directory to process. Can be specified with -dir flag or as the first argument.
@@ -35,10 +39,63 @@ Options:
   -v, --version             Display the application version and exit.
   -h, --help                Display this help message.
 ----------------------------------------------------------------------
+Commands:
+
+  restore                   Restore a backup archive created by this program.
+                            Without ARCHIVE, lists the backups in the backup directory.
+                            TARGET_DIRECTORY defaults to the directory the backup was taken from.
+
+    -backup-dir string      Directory where backups are stored. (default "./media_backups")
+    -only string            Restore only this path (relative to the target). Can be repeated.
+    -dry-run                Only list what would be restored or overwritten.
+    -yes                    Bypass the interactive confirmation prompt.
+
+  backup list               List the backups with their source directory, size and creation time.
+  backup prune              Remove the backups that fall outside the retention policy in config.json.
+
+    -backup-dir string      Directory where backups are stored. (default "./media_backups")
+    -keep-last int          Keep the N most recent backups.
+    -keep-daily int         Keep the newest backup of each of the last N days.
+    -keep-weekly int        Keep the newest backup of each of the last N weeks.
+    -keep-monthly int       Keep the newest backup of each of the last N months.
+    -max-total-size string  Remove the oldest backups until the total is below this size (e.g. 50GB).
+    -dry-run                Only list the backups that would be removed.
+    -yes                    Bypass the interactive confirmation prompt.
+
+  shift                     Shift the timestamps of files in TARGET_DIRECTORY, rewriting their metadata,
+                            renaming them and syncing mtime. Requires exiftool.
+
+    -by string              Shift by this duration (e.g. +1h12m30s, -45s).
+    -ref string             A reference file whose correct time is given by -to.
+    -to string              The correct time of the reference file (YYYY-MM-DD HH:MM:SS).
+                            Without -make/-model/-serial, only files from the same camera are shifted.
+    -make string            Only shift files whose Make matches.
+    -model string           Only shift files whose Model matches.
+    -serial string          Only shift files whose SerialNumber matches.
+    -depth int              Maximum depth for directory traversal. (default -1)
+    -backup-dir string      Directory for the shift journal. (default "./media_backups")
+    -exiftool-path string   Manually specify the full path to the exiftool executable.
+    -dry-run                Only list what would be shifted.
+    -yes                    Bypass the interactive confirmation prompt.
+
+  review                    Interactively assign dates to low-confidence files, folder by folder: type a date,
+                            a range (FROM..TO), or press Enter to accept the inferred date. The dates are written
+                            into the metadata and the files renamed. Requires exiftool.
+
+    -threshold string       List files whose confidence is below this level: offset, metadata, filename,
+                            sidecar, folder. (default: low_confidence.threshold, or "filename")
+    -depth int              Maximum depth for directory traversal. (default -1)
+    -backup-dir string      Directory to store backups. (default "./media_backups")
+    -exiftool-path string   Manually specify the full path to the exiftool executable.
+    -no-backup              Disable the backup before the files are changed.
+    -dry-run                Only list what would be dated and renamed.
+----------------------------------------------------------------------
 Workflow:
   1. The program first checks for the 'exiftool' dependency.
-  2. It then displays an 'Execution Plan' detailing what it will do.
-  3. Finally, it requires you to type 'yes' to proceed, preventing accidental runs.
+  2. It analyzes every file and runs preflight checks (free space for the backup,
+     read-only mounts, write permissions).
+  3. It then displays an 'Execution Plan' detailing what it will do and any problems found.
+  4. Finally, it requires you to type 'yes' to proceed, preventing accidental runs.
 ----------------------------------------------------------------------
 `
 
@@ -70,30 +127,57 @@ You MUST understand the consequences:
     (e.g., 'sudo apt install libimage-exiftool-perl' or 'sudo pacman -S perl-image-exiftool')
 `
 
+// stdin 是所有提示共用的输入读取器。复核模式会连续读取多行输入，
+// 每次新建 bufio.Reader 会丢掉上一个读取器已经缓冲的内容（例如通过管道输入时）。
+var stdin = bufio.NewReader(os.Stdin)
+
 // ShowHelp 打印格式化的帮助信息。
 func ShowHelp() {
-	fmt.Println(helpText)
+	fmt.Print(helpText + "\n")
 }
 
 // ShowExiftoolWarning 打印 exiftool 缺失时的严重警告。
 func ShowExiftoolWarning() {
-	fmt.Println(exiftoolWarningText)
+	fmt.Print(exiftoolWarningText + "\n")
 }
 
 // ShowExecutionPlan 打印一个动态生成的执行计划。
 // --- OLD ---
+// PreflightReport 汇总了确认之前的预检结果。
+type PreflightReport struct {
+	MediaFiles          int      // 找到的媒体文件数量
+	ChangingFiles       int      // 将被修改的文件数量
+	ReviewFiles         int      // 可信度过低、将移入复核目录的文件数量
+	ReviewFolder        string   // 复核目录
+	ScreenCaptures      int      // 识别为截图或录屏的文件数量
+	ExtensionMismatches int      // 扩展名与文件内容不符的文件数量
+	ExtensionsCorrected int      // 其中将改用正确扩展名的文件数量
+	BackupSize          string   // 预计的备份大小，未备份或无法估算时为空
+	BackupFree          string   // 备份所在文件系统的可用空间，未知时为空
+	Problems            []string // 会导致运行中途失败的问题；存在任何一项时程序都不会继续
+	Warnings            []string // 不影响执行的提示
+}
+
+// maxPreflightLines 是执行计划中每类预检结果最多显示的行数。
+const maxPreflightLines = 10
+
 // func ShowExecutionPlan(targetDir string, backupEnabled bool, backupDir string, exiftoolFound bool, imageExts, videoExts []string) {
 // --- NEW ---
-func ShowExecutionPlan(targetDir string, backupEnabled bool, backupDir string, exiftoolFound bool, imageExts, videoExts []string, maxDepth int) {
+func ShowExecutionPlan(targetDir string, backupEnabled bool, backupDir string, backupMode string, exiftoolFound bool, imageExts, videoExts, audioExts []string, maxDepth int, preflight PreflightReport) {
 // -----------
 	fmt.Println("======================================================================")
 	fmt.Println("                            EXECUTION PLAN                            ")
 	fmt.Println("======================================================================")
 	fmt.Printf("\n  TARGET DIRECTORY: %s\n\n", targetDir)
 
-	if backupEnabled {
+	switch {
+	case backupEnabled && backupMode == "selective":
+		fmt.Printf("  BACKUP:           Enabled (selective). Only files that will be modified are backed up to '%s'.\n", backupDir)
+	case backupEnabled && backupMode == "snapshot":
+		fmt.Printf("  BACKUP:           Enabled (snapshot). The directory will be mirrored into '%s' using reflinks or hardlinks.\n", backupDir)
+	case backupEnabled:
 		fmt.Printf("  BACKUP:           Enabled. A backup will be created in '%s'.\n", backupDir)
-	} else {
+	default:
 		fmt.Println("  BACKUP:           Disabled. Files will be modified in-place without a backup.")
 	}
 
@@ -117,21 +201,51 @@ func ShowExecutionPlan(targetDir string, backupEnabled bool, backupDir string, e
 	}
 	// -----------
 
-	fmt.Println("\n  PROCESSING:       Images & Videos")
+	fmt.Printf("\n  FILES:            %d media files found, %d will be modified.\n", preflight.MediaFiles, preflight.ChangingFiles)
+	if preflight.ReviewFiles > 0 {
+		fmt.Printf("  REVIEW:           %d low-confidence files will be moved to '%s' without renaming.\n", preflight.ReviewFiles, preflight.ReviewFolder)
+	}
+	if preflight.ScreenCaptures > 0 {
+		fmt.Printf("  SCREEN CAPTURES:  %d screenshots and screen recordings detected.\n", preflight.ScreenCaptures)
+	}
+	if preflight.ExtensionMismatches > 0 {
+		fmt.Printf("  EXTENSIONS:       %d files have an extension that does not match their content, %d will be corrected.\n", preflight.ExtensionMismatches, preflight.ExtensionsCorrected)
+	}
+	if preflight.BackupSize != "" {
+		if preflight.BackupFree != "" {
+			fmt.Printf("  BACKUP SIZE:      About %s (%s free on the backup filesystem).\n", preflight.BackupSize, preflight.BackupFree)
+		} else {
+			fmt.Printf("  BACKUP SIZE:      About %s (free space unknown).\n", preflight.BackupSize)
+		}
+	}
+	if len(preflight.Problems) == 0 {
+		fmt.Println("  PREFLIGHT:        All checks passed.")
+	} else {
+		fmt.Printf("  PREFLIGHT:        %d problem(s) found. Nothing will be modified until they are fixed:\n", len(preflight.Problems))
+		printPreflightLines("ERROR", preflight.Problems)
+	}
+	printPreflightLines("WARNING", preflight.Warnings)
+
+	fmt.Println("\n  PROCESSING:       Images, Videos & Audio")
 	if len(imageExts) > 0 {
 		fmt.Printf("  Image Types:      %s\n", strings.Join(imageExts, " "))
 	}
 	if len(videoExts) > 0 {
 		fmt.Printf("  Video Types:      %s\n", strings.Join(videoExts, " "))
 	}
+	if len(audioExts) > 0 {
+		fmt.Printf("  Audio Types:      %s\n", strings.Join(audioExts, " "))
+	}
 
 	fmt.Println("\n----------------------------------------------------------------------")
 	fmt.Println("  WORKFLOW OVERVIEW:")
 	fmt.Println("----------------------------------------------------------------------")
 	fmt.Println("  1. [Read Time]:   The program will find the authoritative timestamp")
-	fmt.Println("                  from each file's metadata (EXIF/QuickTime). If metadata")
-	fmt.Println("                  is missing, it will fall back to the file's 'last")
-	fmt.Println("                  modification time'.")
+	fmt.Println("                  from each file's metadata (EXIF/QuickTime, audio tags). If metadata")
+	fmt.Println("                  is missing, it will try the date in the filename, then")
+	fmt.Println("                  XMP/Google Takeout sidecar files, the folder name (if")
+	fmt.Println("                  enabled), and finally the file's 'last modification")
+	fmt.Println("                  time'. Each time is given a confidence.")
 	fmt.Println()
 	fmt.Println("  2. [Rename File]: Files will be renamed based on the authoritative time:")
 	fmt.Println("                  - PREFIX_YYYYMMDD_HHMMSS.ext")
@@ -140,15 +254,26 @@ func ShowExecutionPlan(targetDir string, backupEnabled bool, backupDir string, e
 	fmt.Println("  3. [Sync Info]:")
 	fmt.Println("     - The system file timestamp (mtime) will be synced to the authoritative time.")
 	fmt.Println("     - Metadata timestamps will be enriched (empty fields will be filled).")
+	fmt.Println("       Only times read from metadata are written; audio tags are never rewritten.")
 	fmt.Println("======================================================================")
 }
 
+// printPreflightLines 打印预检结果，超过 maxPreflightLines 行时只显示数量。
+func printPreflightLines(label string, lines []string) {
+	for i, line := range lines {
+		if i == maxPreflightLines {
+			fmt.Printf("                    ... and %d more.\n", len(lines)-maxPreflightLines)
+			break
+		}
+		fmt.Printf("    - %s: %s\n", label, line)
+	}
+}
+
 // RequestConfirmation 请求用户输入 'yes' 来确认一个标准操作。
 // 返回 true 表示用户同意，false 表示拒绝。
 func RequestConfirmation() bool {
 	fmt.Print("\nAre you sure you want to proceed? (Type 'yes' to continue): ")
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
+	input, _ := stdin.ReadString('\n')
 	return strings.TrimSpace(input) == "yes"
 }
 
@@ -156,8 +281,7 @@ func RequestConfirmation() bool {
 // 只有当输入与所需短语完全匹配时才返回 true。
 func RequestCriticalConfirmation(phrase string) bool {
 	fmt.Printf("\nTo proceed in this limited mode, type: '%s' ", phrase)
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
+	input, _ := stdin.ReadString('\n')
 	fmt.Println() // 增加一个换行，保持格式整洁
 	return strings.TrimSpace(input) == phrase
 }
@@ -166,8 +290,7 @@ func RequestCriticalConfirmation(phrase string) bool {
 // 接受 'y' 或 'Y' 作为确认。
 func RequestContinueOnFailure(message string) bool {
 	fmt.Printf("\n%s Continue anyway? (y/N) ", message)
-	reader := bufio.NewReader(os.Stdin)
-	input, _ := reader.ReadString('\n')
+	input, _ := stdin.ReadString('\n')
 	// 将输入转为小写并去除空格
 	return strings.ToLower(strings.TrimSpace(input)) == "y"
 }
@@ -0,0 +1,50 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ReviewFile 是复核列表中的一个文件。
+type ReviewFile struct {
+	Name       string // 文件名（相对于所在文件夹）
+	Time       string // 目前确定的时间
+	Source     string // 该时间的来源
+	Confidence string // 该时间的可信度
+}
+
+// ReviewGroup 是同一文件夹中等待人工指定日期的文件。
+type ReviewGroup struct {
+	Folder           string       // 文件（复核后）所属的文件夹
+	Files            []ReviewFile
+	Suggestion       string       // 推断出的日期或日期范围，格式与用户输入相同；为空表示无法推断
+	SuggestionSource string       // 推断的依据，用于输出
+}
+
+// ShowReviewGroup 打印一组等待复核的文件及推断出的日期。
+func ShowReviewGroup(index, total int, group ReviewGroup) {
+	fmt.Println("\n======================================================================")
+	fmt.Printf("  [%d/%d] FOLDER: %s\n", index, total, group.Folder)
+	fmt.Println("----------------------------------------------------------------------")
+	for _, f := range group.Files {
+		fmt.Printf("  %-40s %s  (%s, confidence: %s)\n", f.Name, f.Time, f.Source, f.Confidence)
+	}
+	if group.Suggestion != "" {
+		fmt.Printf("\n  INFERRED DATE:    %s (%s)\n", group.Suggestion, group.SuggestionSource)
+	}
+}
+
+// RequestReviewDate 请求用户为一组文件输入日期或日期范围，返回去除首尾空白的输入。
+// 有推断日期时，直接回车表示采用推断日期。
+func RequestReviewDate(suggestion string) string {
+	fmt.Println("\n  Enter a date (YYYY-MM-DD [HH:MM[:SS]]) or a range (FROM..TO) for these files,")
+	if suggestion != "" {
+		fmt.Printf("  press Enter to accept %s, 's' to skip this folder or 'q' to stop reviewing: ", suggestion)
+	} else {
+		fmt.Print("  's' (or Enter) to skip this folder or 'q' to stop reviewing: ")
+	}
+	input, err := stdin.ReadString('\n')
+	// 输入已经结束（如管道关闭）时停止复核，而不是把空输入当作采用推断日期
+	if err != nil && strings.TrimSpace(input) == "" { fmt.Println(); return "q" }
+	return strings.TrimSpace(input)
+}
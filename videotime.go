@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 不带时区的视频时间（QuickTime CreateDate 等）的约定。
+const (
+	videoTimeUTC   = "utc"   // QuickTime 规范的做法：记录 UTC 时间
+	videoTimeLocal = "local" // 许多运动相机、无人机和 Android 手机直接记录拍摄地的当地时间
+)
+
+// videoTimeRule 是配置文件中的一条视频时间约定。make 和 model 为空时匹配任何设备（不区分大小写）。
+type videoTimeRule struct {
+	Make       string `json:"make"`
+	Model      string `json:"model"`
+	Convention string `json:"convention"` // "utc" 或 "local"
+}
+
+// defaultVideoTimeRules 排在用户规则之后。没有任何规则匹配时按 UTC 处理。
+var defaultVideoTimeRules = []videoTimeRule{
+	{Make: "Apple", Convention: videoTimeUTC},
+	{Make: "GoPro", Convention: videoTimeLocal},
+	{Make: "DJI", Convention: videoTimeLocal},
+	{Make: "Arashi Vision", Convention: videoTimeLocal}, // Insta360
+}
+
+// videoTimeRules 是校验过的用户规则加上内置规则。
+type videoTimeRules []videoTimeRule
+
+// parseVideoTimeRules 校验配置中的规则，并在其后追加内置规则。
+func parseVideoTimeRules(entries []videoTimeRule) (videoTimeRules, error) {
+	rules := make(videoTimeRules, 0, len(entries)+len(defaultVideoTimeRules))
+	for i, rule := range entries {
+		rule.Convention = strings.ToLower(strings.TrimSpace(rule.Convention))
+		if rule.Convention != videoTimeUTC && rule.Convention != videoTimeLocal {
+			return nil, fmt.Errorf("invalid video time convention %d: '%s', expected '%s' or '%s'", i+1, rule.Convention, videoTimeUTC, videoTimeLocal)
+		}
+		rule.Make, rule.Model = strings.TrimSpace(rule.Make), strings.TrimSpace(rule.Model)
+		rules = append(rules, rule)
+	}
+	return append(rules, defaultVideoTimeRules...), nil
+}
+
+// isUTC 报告这台设备拍摄的视频中不带时区的时间是否为 UTC。
+func (rules videoTimeRules) isUTC(meta mediaMetadata) bool {
+	cameraMake, cameraModel := meta.Get("Make"), meta.Get("Model")
+	for _, rule := range rules {
+		if rule.Make != "" && !strings.EqualFold(rule.Make, cameraMake) { continue }
+		if rule.Model != "" && !strings.EqualFold(rule.Model, cameraModel) { continue }
+		return rule.Convention == videoTimeUTC
+	}
+	return true
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package main
+
+import (
+	"io/fs"
+	"os"
+)
+
+// checkWritable 检查 path 是否带有只读属性。Windows 上没有 access(2)，ACL 造成的拒绝只能在执行时发现。
+func checkWritable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil { return err }
+	if !info.IsDir() && info.Mode().Perm()&0200 == 0 { return &fs.PathError{Op: "access", Path: path, Err: fs.ErrPermission} }
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build unix
+
+package main
+
+import "syscall"
+
+// wOK 是 access(2) 检查写权限的模式位 (W_OK)。
+const wOK = 0x2
+
+// checkWritable 检查当前用户能否写入 path。对于目录，这意味着可以在其中创建、重命名和删除文件。
+// 只读挂载会返回 EROFS。
+func checkWritable(path string) error {
+	return syscall.Access(path, wOK)
+}
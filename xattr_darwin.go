@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+	"unsafe"
+)
+
+// macOS 的 syscall 包没有导出 listxattr、getxattr 和 setxattr，这里直接发起系统调用。
+// 它们比 Linux 多出 position 和 options 两个参数：position 只对资源分叉有意义，取 0；options 为 0 时同样会跟随符号链接。
+
+// readXattrs 读取文件的全部扩展属性（Finder 标签、下载来源等）。文件系统不支持扩展属性时返回空结果。
+// 调用者不应对符号链接调用它。
+func readXattrs(path string) (map[string]string, error) {
+	size, err := listxattr(path, nil)
+	if err != nil {
+		if isXattrUnsupported(err) { return nil, nil }
+		return nil, err
+	}
+	if size == 0 { return nil, nil }
+	buf := make([]byte, size)
+	size, err = listxattr(path, buf)
+	if err != nil { return nil, err }
+
+	xattrs := make(map[string]string)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 { continue }
+		value, err := getXattr(path, string(name))
+		// 属性可能在列出之后被删除
+		if errors.Is(err, syscall.ENOATTR) { continue }
+		if err != nil { return nil, err }
+		xattrs[string(name)] = value
+	}
+	return xattrs, nil
+}
+
+func getXattr(path, name string) (string, error) {
+	size, err := getxattr(path, name, nil)
+	if err != nil { return "", err }
+	buf := make([]byte, size)
+	size, err = getxattr(path, name, buf)
+	if err != nil { return "", err }
+	return string(buf[:size]), nil
+}
+
+// writeXattrs 将扩展属性写回文件。
+func writeXattrs(path string, xattrs map[string]string) error {
+	for name, value := range xattrs {
+		if err := setxattr(path, name, []byte(value)); err != nil { return err }
+	}
+	return nil
+}
+
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP)
+}
+
+func listxattr(path string, buf []byte) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil { return 0, err }
+	n, _, errno := syscall.Syscall6(syscall.SYS_LISTXATTR, uintptr(unsafe.Pointer(p)), uintptr(bufferPointer(buf)), uintptr(len(buf)), 0, 0, 0)
+	if errno != 0 { return 0, errno }
+	return int(n), nil
+}
+
+func getxattr(path, name string, buf []byte) (int, error) {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil { return 0, err }
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil { return 0, err }
+	n, _, errno := syscall.Syscall6(syscall.SYS_GETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(bufferPointer(buf)), uintptr(len(buf)), 0, 0)
+	if errno != 0 { return 0, errno }
+	return int(n), nil
+}
+
+func setxattr(path, name string, value []byte) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil { return err }
+	a, err := syscall.BytePtrFromString(name)
+	if err != nil { return err }
+	_, _, errno := syscall.Syscall6(syscall.SYS_SETXATTR, uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(a)), uintptr(bufferPointer(value)), uintptr(len(value)), 0, 0)
+	if errno != 0 { return errno }
+	return nil
+}
+
+// bufferPointer 返回切片首元素的地址；空切片返回 nil，此时系统调用只报告所需的长度。
+func bufferPointer(b []byte) unsafe.Pointer {
+	if len(b) == 0 { return nil }
+	return unsafe.Pointer(&b[0])
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// readXattrs 读取文件的全部扩展属性。文件系统不支持扩展属性时返回空结果。
+// 注意 syscall 中的这些函数会跟随符号链接，调用者不应对符号链接调用它们。
+func readXattrs(path string) (map[string]string, error) {
+	size, err := syscall.Listxattr(path, nil)
+	if err != nil {
+		if isXattrUnsupported(err) { return nil, nil }
+		return nil, err
+	}
+	if size == 0 { return nil, nil }
+	buf := make([]byte, size)
+	size, err = syscall.Listxattr(path, buf)
+	if err != nil { return nil, err }
+
+	xattrs := make(map[string]string)
+	for _, name := range bytes.Split(buf[:size], []byte{0}) {
+		if len(name) == 0 { continue }
+		value, err := getXattr(path, string(name))
+		// 属性可能在列出之后被删除
+		if errors.Is(err, syscall.ENODATA) { continue }
+		if err != nil { return nil, err }
+		xattrs[string(name)] = value
+	}
+	return xattrs, nil
+}
+
+func getXattr(path, name string) (string, error) {
+	size, err := syscall.Getxattr(path, name, nil)
+	if err != nil { return "", err }
+	buf := make([]byte, size)
+	size, err = syscall.Getxattr(path, name, buf)
+	if err != nil { return "", err }
+	return string(buf[:size]), nil
+}
+
+// writeXattrs 将扩展属性写回文件。
+func writeXattrs(path string, xattrs map[string]string) error {
+	for name, value := range xattrs {
+		if err := syscall.Setxattr(path, name, []byte(value), 0); err != nil { return err }
+	}
+	return nil
+}
+
+func isXattrUnsupported(err error) bool {
+	return errors.Is(err, syscall.ENOTSUP) || errors.Is(err, syscall.EOPNOTSUPP)
+}
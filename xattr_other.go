@@ -0,0 +1,16 @@
+//go:build !linux && !darwin
+
+package main
+
+import "errors"
+
+var errXattrUnsupported = errors.New("extended attributes are not supported on this platform")
+
+// readXattrs 在 Linux 和 macOS 以外的平台上不读取扩展属性。
+func readXattrs(path string) (map[string]string, error) { return nil, nil }
+
+// writeXattrs 在 Linux 和 macOS 以外的平台上无法还原扩展属性。
+func writeXattrs(path string, xattrs map[string]string) error {
+	if len(xattrs) == 0 { return nil }
+	return errXattrUnsupported
+}